@@ -0,0 +1,64 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "time"
+)
+
+// ErrUnsupported is returned by Client methods the backend cannot perform.
+var ErrUnsupported = errors.New("operation not supported by this backend")
+
+// Client is the full set of operations the SDK offers. Both backends implement
+// it; operations a backend can't perform return ErrUnsupported, so application
+// code can depend on Client and check for that instead of a concrete type.
+type Client interface {
+    PredictorStore
+    CreateModel(ctx context.Context, spec ModelSpec) (string, error)
+    GetModelStatus(ctx context.Context, name string) (string, error)
+    WaitForModel(ctx context.Context, name string, poll time.Duration) error
+    MonitorModels(ctx context.Context, names []string, interval time.Duration) (<-chan ModelStatusEvent, error)
+    Predict(ctx context.Context, model string, input map[string]interface{}, opts ...PredictOption) (map[string]interface{}, error)
+    PredictEnsemble(ctx context.Context, models []string, input map[string]interface{}, strategy VotingStrategy) (Prediction, error)
+    DropAllModels(ctx context.Context, project string, confirm bool) (int64, error)
+}
+
+var (
+    _ Client = (*MindsDBClient)(nil)
+    _ Client = (*MySQLStore)(nil)
+)
+
+// CreateModel returns ErrUnsupported; models live in MindsDB, not MongoDB.
+func (client *MindsDBClient) CreateModel(ctx context.Context, spec ModelSpec) (string, error) {
+    return "", ErrUnsupported
+}
+
+// GetModelStatus returns ErrUnsupported; models live in MindsDB, not MongoDB.
+func (client *MindsDBClient) GetModelStatus(ctx context.Context, name string) (string, error) {
+    return "", ErrUnsupported
+}
+
+// WaitForModel returns ErrUnsupported; models live in MindsDB, not MongoDB.
+func (client *MindsDBClient) WaitForModel(ctx context.Context, name string, poll time.Duration) error {
+    return ErrUnsupported
+}
+
+// MonitorModels returns ErrUnsupported; models live in MindsDB, not MongoDB.
+func (client *MindsDBClient) MonitorModels(ctx context.Context, names []string, interval time.Duration) (<-chan ModelStatusEvent, error) {
+    return nil, ErrUnsupported
+}
+
+// Predict returns ErrUnsupported; models live in MindsDB, not MongoDB.
+func (client *MindsDBClient) Predict(ctx context.Context, model string, input map[string]interface{}, opts ...PredictOption) (map[string]interface{}, error) {
+    return nil, ErrUnsupported
+}
+
+// PredictEnsemble returns ErrUnsupported; models live in MindsDB, not MongoDB.
+func (client *MindsDBClient) PredictEnsemble(ctx context.Context, models []string, input map[string]interface{}, strategy VotingStrategy) (Prediction, error) {
+    return Prediction{}, ErrUnsupported
+}
+
+// DropAllModels returns ErrUnsupported; models live in MindsDB, not MongoDB.
+func (client *MindsDBClient) DropAllModels(ctx context.Context, project string, confirm bool) (int64, error) {
+    return 0, ErrUnsupported
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Config holds the server settings read by LoadConfigFromEnv.
+type Config struct {
+    // MongoURI is the MongoDB connection string, without credentials.
+    MongoURI string
+    // MongoUsername and MongoPassword authenticate against MongoAuthSource
+    // when MongoUsername is set. An empty MongoAuthSource uses the URI's
+    // authSource option or database, and otherwise "admin".
+    MongoUsername   string
+    MongoPassword   string
+    MongoAuthSource string
+    // Database and Collection hold the predictors.
+    Database   string
+    Collection string
+    // MySQLDSN, when set, stores predictors in MindsDB over the MySQL protocol
+    // instead of MongoDB. LoadConfigFromEnv rejects setting both it and MongoURI.
+    MySQLDSN string
+    // ListenAddr is the address the HTTP server listens on.
+    ListenAddr string
+    // RequestTimeout bounds how long a single HTTP request may run.
+    // Zero disables the limit.
+    RequestTimeout time.Duration
+    // CORS controls which browser origins may call the API. The zero value
+    // allows same-origin requests only.
+    CORS CORSConfig
+}
+
+// Defaults used by LoadConfigFromEnv for unset variables.
+const (
+    defaultDatabase   = "mindsdb"
+    defaultCollection = "predictors"
+    defaultListenAddr = ":8080"
+
+    defaultRequestTimeout = 30 * time.Second
+)
+
+// defaultCORSHeaders are the request headers cross-origin callers may send
+// when MINDSDB_CORS_HEADERS is unset. JSON request bodies need Content-Type.
+var defaultCORSHeaders = []string{"Content-Type", "X-Request-ID"}
+
+// LoadConfigFromEnv reads the configuration from these environment variables.
+// Exactly one of MINDSDB_MONGO_URI and MINDSDB_MYSQL_DSN must be set, as it
+// selects the store:
+//
+//    MINDSDB_MONGO_URI         MongoDB connection string, required unless MINDSDB_MYSQL_DSN is set
+//    MINDSDB_MONGO_USERNAME    MongoDB user, optional
+//    MINDSDB_MONGO_PASSWORD    MongoDB password, optional
+//    MINDSDB_MONGO_AUTH_SOURCE database to authenticate against, default from the URI, else "admin"
+//    MINDSDB_DB                database name, default "mindsdb"
+//    MINDSDB_COLLECTION        collection name, default "predictors"
+//    MINDSDB_MYSQL_DSN         MySQL-driver DSN for MindsDB, instead of MINDSDB_MONGO_URI
+//    MINDSDB_ADDR              listen address, default ":8080"
+//    MINDSDB_REQUEST_TIMEOUT   per-request timeout such as "10s", default "30s", "0" disables it
+//    MINDSDB_CORS_ORIGINS      comma-separated browser origins allowed to call the API, optional
+//    MINDSDB_CORS_HEADERS      comma-separated request headers they may send, default "Content-Type,X-Request-ID"
+//    MINDSDB_CORS_CREDENTIALS  "true" to let them send cookies and authorization headers, default "false"
+func LoadConfigFromEnv() (Config, error) {
+    cfg := Config{
+        MongoURI:        os.Getenv("MINDSDB_MONGO_URI"),
+        MongoUsername:   os.Getenv("MINDSDB_MONGO_USERNAME"),
+        MongoPassword:   os.Getenv("MINDSDB_MONGO_PASSWORD"),
+        MongoAuthSource: os.Getenv("MINDSDB_MONGO_AUTH_SOURCE"),
+        Database:        envOr("MINDSDB_DB", defaultDatabase),
+        Collection:      envOr("MINDSDB_COLLECTION", defaultCollection),
+        MySQLDSN:        os.Getenv("MINDSDB_MYSQL_DSN"),
+        ListenAddr:      envOr("MINDSDB_ADDR", defaultListenAddr),
+    }
+    if cfg.MongoURI == "" && cfg.MySQLDSN == "" {
+        return Config{}, errors.New("invalid configuration: MINDSDB_MONGO_URI is required, e.g. mongodb+srv://cluster0.example.mongodb.net/ (or set MINDSDB_MYSQL_DSN to use MindsDB directly)")
+    }
+    if cfg.MongoURI != "" && cfg.MySQLDSN != "" {
+        return Config{}, errors.New("invalid configuration: MINDSDB_MONGO_URI and MINDSDB_MYSQL_DSN are both set; set only the one for the store to use")
+    }
+
+    cfg.RequestTimeout = defaultRequestTimeout
+    if value := os.Getenv("MINDSDB_REQUEST_TIMEOUT"); value != "" {
+        timeout, err := time.ParseDuration(value)
+        if err != nil || timeout < 0 {
+            return Config{}, fmt.Errorf("invalid configuration: MINDSDB_REQUEST_TIMEOUT must be a non-negative duration such as 10s, got %q", value)
+        }
+        cfg.RequestTimeout = timeout
+    }
+
+    cfg.CORS.AllowedOrigins = splitList(os.Getenv("MINDSDB_CORS_ORIGINS"))
+    cfg.CORS.AllowedHeaders = defaultCORSHeaders
+    if value := os.Getenv("MINDSDB_CORS_HEADERS"); value != "" {
+        cfg.CORS.AllowedHeaders = splitList(value)
+    }
+    if value := os.Getenv("MINDSDB_CORS_CREDENTIALS"); value != "" {
+        credentials, err := strconv.ParseBool(value)
+        if err != nil {
+            return Config{}, fmt.Errorf("invalid configuration: MINDSDB_CORS_CREDENTIALS must be true or false, got %q", value)
+        }
+        cfg.CORS.AllowCredentials = credentials
+    }
+    if cfg.CORS.Validate() != nil {
+        return Config{}, errors.New(`invalid configuration: MINDSDB_CORS_CREDENTIALS cannot be true when MINDSDB_CORS_ORIGINS contains "*"`)
+    }
+    return cfg, nil
+}
+
+// splitList splits a comma-separated value, dropping blank entries.
+func splitList(value string) []string {
+    var items []string
+    for _, item := range strings.Split(value, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+// envOr returns the value of the environment variable key, or fallback if it
+// is unset or empty.
+func envOr(key, fallback string) string {
+    if value := os.Getenv(key); value != "" {
+        return value
+    }
+    return fallback
+}
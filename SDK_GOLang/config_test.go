@@ -0,0 +1,77 @@
+package main
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestLoadConfigFromEnvCORS(t *testing.T) {
+    t.Setenv("MINDSDB_MONGO_URI", "mongodb://localhost:27017")
+    for _, key := range []string{"MINDSDB_MYSQL_DSN", "MINDSDB_CORS_ORIGINS", "MINDSDB_CORS_HEADERS", "MINDSDB_CORS_CREDENTIALS"} {
+        t.Setenv(key, "")
+    }
+
+    t.Run("default", func(t *testing.T) {
+        cfg, err := LoadConfigFromEnv()
+        if err != nil {
+            t.Fatalf("LoadConfigFromEnv: %v", err)
+        }
+        if len(cfg.CORS.AllowedOrigins) != 0 || cfg.CORS.AllowCredentials || !reflect.DeepEqual(cfg.CORS.AllowedHeaders, defaultCORSHeaders) {
+            t.Errorf("CORS = %+v, want same-origin only", cfg.CORS)
+        }
+    })
+
+    t.Run("origins", func(t *testing.T) {
+        t.Setenv("MINDSDB_CORS_ORIGINS", "https://app.example.com, https://admin.example.com,")
+        t.Setenv("MINDSDB_CORS_HEADERS", "Content-Type,Authorization")
+        t.Setenv("MINDSDB_CORS_CREDENTIALS", "true")
+        cfg, err := LoadConfigFromEnv()
+        if err != nil {
+            t.Fatalf("LoadConfigFromEnv: %v", err)
+        }
+        want := CORSConfig{
+            AllowedOrigins:   []string{"https://app.example.com", "https://admin.example.com"},
+            AllowedHeaders:   []string{"Content-Type", "Authorization"},
+            AllowCredentials: true,
+        }
+        if !reflect.DeepEqual(cfg.CORS, want) {
+            t.Errorf("CORS = %+v, want %+v", cfg.CORS, want)
+        }
+    })
+
+    t.Run("wildcard with credentials", func(t *testing.T) {
+        t.Setenv("MINDSDB_CORS_ORIGINS", "*")
+        t.Setenv("MINDSDB_CORS_CREDENTIALS", "true")
+        if _, err := LoadConfigFromEnv(); err == nil {
+            t.Error("LoadConfigFromEnv: err = nil")
+        }
+    })
+
+    t.Run("bad credentials", func(t *testing.T) {
+        t.Setenv("MINDSDB_CORS_CREDENTIALS", "sometimes")
+        if _, err := LoadConfigFromEnv(); err == nil {
+            t.Error("LoadConfigFromEnv: err = nil")
+        }
+    })
+}
+
+func TestLoadConfigFromEnvStore(t *testing.T) {
+    tests := []struct {
+        name, mongoURI, mysqlDSN string
+        wantErr                  bool
+    }{
+        {"mongo", "mongodb://localhost:27017", "", false},
+        {"mysql", "", "root@tcp(localhost:47334)/mindsdb", false},
+        {"neither", "", "", true},
+        {"both", "mongodb://localhost:27017", "root@tcp(localhost:47334)/mindsdb", true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            t.Setenv("MINDSDB_MONGO_URI", tt.mongoURI)
+            t.Setenv("MINDSDB_MYSQL_DSN", tt.mysqlDSN)
+            if _, err := LoadConfigFromEnv(); (err != nil) != tt.wantErr {
+                t.Errorf("LoadConfigFromEnv error = %v, want error %v", err, tt.wantErr)
+            }
+        })
+    }
+}
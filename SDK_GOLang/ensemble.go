@@ -0,0 +1,154 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sync"
+)
+
+// VotingMethod selects how PredictEnsemble combines the models' predictions.
+type VotingMethod int
+
+const (
+    // Majority picks the most common prediction, for classification models.
+    // Ties go to the value predicted by the earliest model in the list.
+    Majority VotingMethod = iota
+    // Mean averages the predictions, for regression models.
+    Mean
+)
+
+// VotingStrategy describes how PredictEnsemble combines predictions.
+type VotingStrategy struct {
+    Method VotingMethod
+    // Target is the column holding each model's prediction.
+    Target string
+}
+
+// MajorityVote returns a strategy that picks the most common value of target.
+func MajorityVote(target string) VotingStrategy {
+    return VotingStrategy{Method: Majority, Target: target}
+}
+
+// MeanVote returns a strategy that averages the numeric values of target.
+func MeanVote(target string) VotingStrategy {
+    return VotingStrategy{Method: Mean, Target: target}
+}
+
+// Prediction is the combined result of PredictEnsemble.
+type Prediction struct {
+    // Value is the combined prediction: a vote winner for Majority and a
+    // float64 for Mean.
+    Value interface{}
+    // Results holds the full result row of each model that answered.
+    Results map[string]map[string]interface{}
+    // Errors holds the error of each model that didn't contribute a value.
+    Errors map[string]error
+}
+
+// PredictEnsemble queries every model concurrently with the same input and
+// combines their values of strategy.Target. Models that fail are left out of
+// the vote and reported in Prediction.Errors; an error is returned only if
+// none of them produced a usable value.
+func (store *MySQLStore) PredictEnsemble(ctx context.Context, models []string, input map[string]interface{}, strategy VotingStrategy) (Prediction, error) {
+    if len(models) == 0 {
+        return Prediction{}, errors.New("at least one model is required")
+    }
+    if strategy.Target == "" {
+        return Prediction{}, errors.New("voting strategy: target column is required")
+    }
+    seen := make(map[string]bool, len(models))
+    for _, model := range models {
+        if seen[model] {
+            return Prediction{}, fmt.Errorf("model %s is listed more than once", model)
+        }
+        seen[model] = true
+    }
+
+    results := make([]map[string]interface{}, len(models))
+    errs := make([]error, len(models))
+    var wg sync.WaitGroup
+    for i, model := range models {
+        wg.Add(1)
+        go func(i int, model string) {
+            defer wg.Done()
+            results[i], errs[i] = store.Predict(ctx, model, input)
+        }(i, model)
+    }
+    wg.Wait()
+    return vote(models, results, errs, strategy)
+}
+
+// vote combines the answers of models, where results[i] and errs[i] are the
+// outcome of querying models[i], according to strategy.
+func vote(models []string, results []map[string]interface{}, errs []error, strategy VotingStrategy) (Prediction, error) {
+    prediction := Prediction{
+        Results: make(map[string]map[string]interface{}),
+        Errors:  make(map[string]error),
+    }
+    var values []interface{}
+    for i, model := range models {
+        if errs[i] != nil {
+            prediction.Errors[model] = errs[i]
+            continue
+        }
+        prediction.Results[model] = results[i]
+
+        value, ok := results[i][strategy.Target]
+        switch {
+        case !ok:
+            prediction.Errors[model] = fmt.Errorf("model %s returned no %s column", model, strategy.Target)
+        case strategy.Method == Mean && !isFloat(value):
+            prediction.Errors[model] = fmt.Errorf("model %s returned non-numeric %s %v", model, strategy.Target, value)
+        default:
+            values = append(values, value)
+        }
+    }
+
+    if len(values) == 0 {
+        all := make([]error, 0, len(prediction.Errors))
+        for _, model := range models {
+            all = append(all, prediction.Errors[model])
+        }
+        return prediction, fmt.Errorf("no model in the ensemble produced a prediction: %w", errors.Join(all...))
+    }
+
+    switch strategy.Method {
+    case Majority:
+        prediction.Value = majority(values)
+    case Mean:
+        prediction.Value = mean(values)
+    default:
+        return prediction, fmt.Errorf("unknown voting method %d", strategy.Method)
+    }
+    return prediction, nil
+}
+
+func isFloat(value interface{}) bool {
+    _, ok := value.(float64)
+    return ok
+}
+
+// majority returns the most common value, preferring the earliest on a tie.
+func majority(values []interface{}) interface{} {
+    counts := make(map[interface{}]int, len(values))
+    for _, value := range values {
+        counts[value]++
+    }
+    winner := values[0]
+    for _, value := range values {
+        if counts[value] > counts[winner] {
+            winner = value
+        }
+    }
+    return winner
+}
+
+// mean returns the average of values, which must all be float64.
+func mean(values []interface{}) float64 {
+    var sum float64
+    for _, value := range values {
+        sum += value.(float64)
+    }
+    return sum / float64(len(values))
+}
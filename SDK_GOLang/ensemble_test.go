@@ -0,0 +1,87 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "testing"
+)
+
+func TestVoteMajority(t *testing.T) {
+    models := []string{"a", "b", "c", "d"}
+    results := []map[string]interface{}{
+        {"label": "spam"},
+        {"label": "ham"},
+        {"label": "ham"},
+        nil,
+    }
+    errs := []error{nil, nil, nil, errors.New("timeout")}
+
+    prediction, err := vote(models, results, errs, MajorityVote("label"))
+    if err != nil {
+        t.Fatalf("vote: %v", err)
+    }
+    if prediction.Value != "ham" {
+        t.Errorf("Value = %v, want ham", prediction.Value)
+    }
+    if len(prediction.Results) != 3 {
+        t.Errorf("Results has %d models, want 3", len(prediction.Results))
+    }
+    if prediction.Errors["d"] == nil || len(prediction.Errors) != 1 {
+        t.Errorf("Errors = %v, want only d", prediction.Errors)
+    }
+}
+
+func TestVoteMajorityTie(t *testing.T) {
+    models := []string{"a", "b"}
+    results := []map[string]interface{}{{"label": "spam"}, {"label": "ham"}}
+
+    prediction, err := vote(models, results, make([]error, 2), MajorityVote("label"))
+    if err != nil {
+        t.Fatalf("vote: %v", err)
+    }
+    if prediction.Value != "spam" {
+        t.Errorf("Value = %v, want spam from the earliest model", prediction.Value)
+    }
+}
+
+func TestVoteMean(t *testing.T) {
+    models := []string{"a", "b", "c", "d"}
+    results := []map[string]interface{}{
+        {"rental_price": 1000.0},
+        {"rental_price": 2000.0},
+        {"rental_price": "n/a"},
+        {"sqft": 900.0},
+    }
+
+    prediction, err := vote(models, results, make([]error, 4), MeanVote("rental_price"))
+    if err != nil {
+        t.Fatalf("vote: %v", err)
+    }
+    if prediction.Value != 1500.0 {
+        t.Errorf("Value = %v, want 1500", prediction.Value)
+    }
+    for _, model := range []string{"c", "d"} {
+        if prediction.Errors[model] == nil {
+            t.Errorf("Errors[%s] = nil, want an error", model)
+        }
+    }
+}
+
+func TestVoteNoValues(t *testing.T) {
+    models := []string{"a", "b"}
+    errA, errB := errors.New("a failed"), errors.New("b failed")
+
+    _, err := vote(models, make([]map[string]interface{}, 2), []error{errA, errB}, MeanVote("rental_price"))
+    if !errors.Is(err, errA) || !errors.Is(err, errB) {
+        t.Errorf("err = %v, want both model errors", err)
+    }
+}
+
+func TestPredictEnsembleDuplicateModels(t *testing.T) {
+    store, _ := newMockStore(t)
+
+    _, err := store.PredictEnsemble(context.Background(), []string{"a", "b", "a"}, nil, MajorityVote("label"))
+    if err == nil {
+        t.Fatal("PredictEnsemble with a duplicate model: err = nil")
+    }
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "io"
+    "log/slog"
+    "net/http"
+    "os"
+    "time"
+)
+
+// Logger is the structured logger used by the client and the HTTP handlers.
+// fields are alternating keys and values, as in log/slog.
+type Logger interface {
+    Info(msg string, fields ...interface{})
+    Error(msg string, fields ...interface{})
+}
+
+// NewJSONLogger returns a Logger that writes one JSON object per entry to w.
+func NewJSONLogger(w io.Writer) Logger {
+    return slog.New(slog.NewJSONHandler(w, nil))
+}
+
+var logger Logger = NewJSONLogger(os.Stderr)
+
+// SetLogger replaces the package logger, which defaults to JSON on stderr. It
+// should be called before any client or handler is in use.
+func SetLogger(l Logger) {
+    logger = l
+}
+
+// requestIDHeader is the response header carrying the request ID.
+const requestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID RequestLoggingMiddleware gave the
+// request, or "" if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+    id, _ := ctx.Value(requestIDKey{}).(string)
+    return id
+}
+
+// newRequestID returns a random 16 character hex ID.
+func newRequestID() string {
+    b := make([]byte, 8)
+    if _, err := rand.Read(b); err != nil {
+        return ""
+    }
+    return hex.EncodeToString(b)
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+    http.ResponseWriter
+    status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+    rec.status = status
+    rec.ResponseWriter.WriteHeader(status)
+}
+
+// RequestLoggingMiddleware gives every request an ID, stored in its context
+// and returned in the X-Request-ID header, and logs the method, path, status
+// and latency once the request completes.
+func RequestLoggingMiddleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        start := time.Now()
+        id := newRequestID()
+        w.Header().Set(requestIDHeader, id)
+
+        rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+        next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+
+        logger.Info("request",
+            "request_id", id,
+            "method", r.Method,
+            "path", r.URL.Path,
+            "status", rec.status,
+            "latency_ms", time.Since(start).Milliseconds(),
+        )
+    })
+}
+
+// logRequestError logs err as the cause of a failed request.
+func logRequestError(r *http.Request, msg string, err error) {
+    logger.Error(msg,
+        "request_id", RequestIDFromContext(r.Context()),
+        "method", r.Method,
+        "path", r.URL.Path,
+        "error", err,
+    )
+}
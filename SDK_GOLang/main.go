@@ -1,137 +1,42 @@
-// package main
-
-// import (
-//     "database/sql"
-//     "context"
-//     "fmt"
-//     "log"
-//     "time"
-//     _ "github.com/go-sql-driver/mysql"
-// )
-
-// // MindsDBClient represents a client for MindsDB.
-// type MindsDBClient struct {
-//     db *sql.DB
-// }
-
-// // NewMindsDBClient initializes a new MindsDB client.
-// func NewMindsDBClient(dsn string) (*MindsDBClient, error) {
-//     db, err := sql.Open("mysql", dsn)
-//     if err != nil {
-//         return nil, fmt.Errorf("failed to open database: %w", err)
-//     }
-
-//     // Test the connection with a timeout
-//     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-//     defer cancel()
-
-//     if err := db.PingContext(ctx); err != nil {
-//         return nil, fmt.Errorf("failed to ping database: %w", err)
-//     }
-
-//     return &MindsDBClient{db: db}, nil
-// }
-
-// // Close closes the database connection.
-// func (client *MindsDBClient) Close() error {
-//     return client.db.Close()
-// }
-
-// // QueryPredictors retrieves a limited number of predictors from the database.
-// func (client *MindsDBClient) QueryPredictors(limit int) ([]string, error) {
-//     query := fmt.Sprintf("SELECT name FROM mindsdb.predictors LIMIT %d;", limit)
-//     rows, err := client.db.Query(query)
-//     if err != nil {
-//         return nil, fmt.Errorf("error executing query: %w", err)
-//     }
-//     defer rows.Close()
-
-//     var predictors []string
-//     for rows.Next() {
-//         var name string
-//         if err := rows.Scan(&name); err != nil {
-//             return nil, fmt.Errorf("error scanning row: %w", err)
-//         }
-//         predictors = append(predictors, name)
-//     }
-
-//     return predictors, nil
-// }
-
-// // CreatePredictorsTable creates the predictors table if it doesn't exist.
-// func (client *MindsDBClient) CreatePredictorsTable() error {
-//     query := `
-//     CREATE TABLE IF NOT EXISTS predictors (
-//         id INT AUTO_INCREMENT PRIMARY KEY,
-//         name VARCHAR(255) NOT NULL,
-//         created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-//         updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
-//     );`
-
-//     _, err := client.db.Exec(query)
-//     return err
-// }
-
-// func main() {
-//     // Set the Data Source Name (DSN)
-//     dsn := "root@tcp(localhost:47334)/mindsdb?timeout=10s"
-//     log.Println("Attempting to connect to MindsDB...")
-
-//     client, err := NewMindsDBClient(dsn)
-//     if err != nil {
-//         log.Fatalf("Error creating MindsDB client: %v", err)
-//     }
-//     defer client.Close()
-
-//     fmt.Println("Successfully connected to MindsDB!")
-
-//     // Create the predictors table if it doesn't exist
-//     if err := client.CreatePredictorsTable(); err != nil {
-//         log.Fatalf("Error creating predictors table: %v", err)
-//     }
-
-//     // Optional: Insert sample data into the predictors table
-//     sampleData := []string{"Predictor 1", "Predictor 2", "Predictor 3"}
-//     for _, name := range sampleData {
-//         _, err := client.db.Exec("INSERT INTO predictors (name) VALUES (?);", name)
-//         if err != nil {
-//             log.Fatalf("Error inserting sample data: %v", err)
-//         }
-//     }
-
-//     // Query data from MindsDB
-//     predictors, err := client.QueryPredictors(10)
-//     if err != nil {
-//         log.Fatalf("Error querying predictors: %v", err)
-//     }
-
-//     // Print the query results
-//     fmt.Println("Predictors:")
-//     for _, predictor := range predictors {
-//         fmt.Println(predictor)
-//     }
-// }
-
-
 package main
 
 import (
     "context"
     "encoding/json"
+    "errors"
     "fmt"
-    "log"
+    "hash/fnv"
+    "math/rand/v2"
     "net/http"
+    "net/url"
+    "os"
+    "os/signal"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "syscall"
     "time"
+    "unicode/utf8"
 
     "go.mongodb.org/mongo-driver/mongo"
     "go.mongodb.org/mongo-driver/mongo/options"
     "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/event"
     "github.com/gorilla/mux"
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/collectors"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // MindsDBClient represents a client for MongoDB.
 type MindsDBClient struct {
+    client     *mongo.Client
     collection *mongo.Collection
+    shards     []*mongo.Collection
+    audit      *mongo.Collection
+    fields     FieldMap
 }
 
 // Predictor represents the structure for predictor.
@@ -140,105 +45,1792 @@ type Predictor struct {
     Name string `json:"name" bson:"name"`
 }
 
+// maxPredictorNameLength is the longest name accepted, matching the width of
+// the MySQL name column.
+const maxPredictorNameLength = 255
+
+// ValidationError reports a predictor field with an invalid value.
+type ValidationError struct {
+    Field   string
+    Message string
+}
+
+func (e *ValidationError) Error() string {
+    return e.Field + ": " + e.Message
+}
+
+// Validate checks that the predictor can be stored. It returns a
+// *ValidationError if the name is blank or longer than 255 characters.
+func (predictor Predictor) Validate() error {
+    if strings.TrimSpace(predictor.Name) == "" {
+        return &ValidationError{Field: "name", Message: "must not be empty"}
+    }
+    if utf8.RuneCountInString(predictor.Name) > maxPredictorNameLength {
+        return &ValidationError{Field: "name", Message: fmt.Sprintf("must be at most %d characters", maxPredictorNameLength)}
+    }
+    return nil
+}
+
+// PredictorStore is the storage backend the HTTP handlers depend on. It is
+// implemented by the MongoDB-backed MindsDBClient and by MySQLStore.
+type PredictorStore interface {
+    CreatePredictor(ctx context.Context, predictor Predictor) (string, error)
+    CreatePredictors(ctx context.Context, predictors []Predictor) ([]string, error)
+    GetPredictors(ctx context.Context) ([]Predictor, error)
+    GetPredictorByID(ctx context.Context, id string) (*Predictor, error)
+    GetPredictorsPaged(ctx context.Context, limit, offset int64) ([]Predictor, error)
+    GetPredictorsCursor(ctx context.Context, afterID string, limit int) ([]Predictor, string, error)
+    SearchPredictors(ctx context.Context, query string, limit, offset int64) ([]Predictor, error)
+    CountPredictors(ctx context.Context) (int64, error)
+    EstimatePredictorCount(ctx context.Context) (int64, error)
+    UpdatePredictor(ctx context.Context, id string, predictor Predictor) error
+    RenamePredictor(ctx context.Context, id, newName string) error
+    PatchPredictor(ctx context.Context, id string, patch map[string]interface{}) error
+    DeletePredictor(ctx context.Context, id string) error
+    DeletePredictorsByName(ctx context.Context, prefix string) (int64, error)
+    Ping(ctx context.Context) error
+    Close(ctx context.Context) error
+}
+
+var (
+    _ PredictorStore = (*MindsDBClient)(nil)
+    _ PredictorStore = (*MySQLStore)(nil)
+)
+
+var (
+    // ErrPredictorNotFound is returned when no predictor matches the given ID.
+    ErrPredictorNotFound = errors.New("predictor not found")
+    // ErrDuplicatePredictor is returned when another predictor already has the name.
+    ErrDuplicatePredictor = errors.New("predictor with this name already exists")
+    // ErrInvalidPredictorID is returned when an ID is not valid for the store, such as a
+    // malformed ObjectID hex string for MongoDB or a non-numeric row ID for MySQL.
+    ErrInvalidPredictorID = errors.New("invalid predictor id")
+    // ErrEmptyPrefix is returned by DeletePredictorsByName for an empty prefix,
+    // which would match every predictor.
+    ErrEmptyPrefix = errors.New("name prefix must not be empty")
+    // ErrAuditDisabled is returned when reading history from a client created without WithAudit.
+    ErrAuditDisabled = errors.New("audit logging is not enabled")
+    // ErrReplicaSetRequired is returned by WatchPredictors when MongoDB runs as a
+    // standalone server, which does not support change streams.
+    ErrReplicaSetRequired = errors.New("change streams require a replica set or sharded cluster")
+    // ErrChangeStreamInvalidated is sent by WatchPredictors when MongoDB closes
+    // the change stream, such as after the database is dropped. Call
+    // WatchPredictors again to start a new stream.
+    ErrChangeStreamInvalidated = errors.New("predictor change stream was invalidated")
+)
+
+// BatchError reports the items of a bulk operation that failed, keyed by their
+// index in the input slice. Items in neither Failed nor Skipped were applied.
+type BatchError struct {
+    Failed map[int]error
+    // Skipped lists, in order, the items an ordered operation did not attempt
+    // because an earlier item failed.
+    Skipped []int
+}
+
+func (e *BatchError) Error() string {
+    indices := make([]int, 0, len(e.Failed))
+    for i := range e.Failed {
+        indices = append(indices, i)
+    }
+    sort.Ints(indices)
+
+    parts := make([]string, len(indices))
+    for n, i := range indices {
+        parts[n] = fmt.Sprintf("item %d: %v", i, e.Failed[i])
+    }
+    if len(e.Skipped) > 0 {
+        parts = append(parts, fmt.Sprintf("%d items skipped", len(e.Skipped)))
+    }
+    return "batch partially failed: " + strings.Join(parts, "; ")
+}
+
+// Audit operations recorded in AuditEntry.Operation.
+const (
+    AuditCreate = "create"
+    AuditUpdate = "update"
+    AuditDelete = "delete"
+)
+
+// AuditEntry records a single change made to a predictor.
+type AuditEntry struct {
+    Operation   string    `json:"operation" bson:"operation"`
+    PredictorID string    `json:"predictor_id" bson:"predictor_id"`
+    Timestamp   time.Time `json:"timestamp" bson:"timestamp"`
+    User        string    `json:"user,omitempty" bson:"user,omitempty"`
+}
+
+type auditUserKey struct{}
+
+// WithAuditUser returns a context that attributes audit entries written with it to user.
+func WithAuditUser(ctx context.Context, user string) context.Context {
+    return context.WithValue(ctx, auditUserKey{}, user)
+}
+
+// FieldMap maps Predictor fields onto the field names used in the MongoDB
+// documents, for collections that don't follow the default schema.
+type FieldMap struct {
+    Name string
+}
+
+// DefaultFieldMap matches the bson tags on Predictor.
+var DefaultFieldMap = FieldMap{Name: "name"}
+
 // NewMindsDBClient initializes a new MongoDB client for MindsDB using MongoDB Atlas.
-func NewMindsDBClient(uri string, dbName string, collectionName string) (*MindsDBClient, error) {
-    clientOptions := options.Client().ApplyURI(uri)
+// WithDatabase and WithCollection are required; the other options are optional.
+func NewMindsDBClient(uri string, opts ...ClientOption) (*MindsDBClient, error) {
+    return NewMindsDBClientContext(context.Background(), uri, opts...)
+}
+
+// NewMindsDBClientContext is NewMindsDBClient with a context that bounds the
+// whole connection process, including any retries set up by WithConnectRetries.
+func NewMindsDBClientContext(ctx context.Context, uri string, opts ...ClientOption) (*MindsDBClient, error) {
+    cfg := defaultClientConfig()
+    for _, opt := range opts {
+        opt(&cfg)
+    }
+    if err := cfg.validate(); err != nil {
+        return nil, err
+    }
+
+    clientOptions := options.Client().ApplyURI(uri).SetConnectTimeout(cfg.connectTimeout)
+    if err := clientOptions.Validate(); err != nil {
+        // Parsing a mongodb+srv URI looks up its SRV and TXT records, so DNS
+        // failures surface here rather than on connect.
+        if strings.HasPrefix(uri, "mongodb+srv://") {
+            return nil, fmt.Errorf("failed to resolve mongodb+srv URI for %s: %v", uriHost(uri), err)
+        }
+        return nil, fmt.Errorf("invalid MongoDB URI: %v", err)
+    }
+    if cfg.tlsConfig != nil {
+        clientOptions.SetTLSConfig(cfg.tlsConfig)
+    }
+    if cfg.auth != nil {
+        clientOptions.SetAuth(withURIAuthSource(*cfg.auth, uri))
+    }
+    if cfg.serverSelectionTimeout > 0 {
+        clientOptions.SetServerSelectionTimeout(cfg.serverSelectionTimeout)
+    }
+    if cfg.maxPoolSize > 0 {
+        clientOptions.SetMaxPoolSize(cfg.maxPoolSize)
+    }
+    if cfg.minPoolSize > 0 {
+        clientOptions.SetMinPoolSize(cfg.minPoolSize)
+    }
+    if cfg.maxConnIdleTime > 0 {
+        clientOptions.SetMaxConnIdleTime(cfg.maxConnIdleTime)
+    }
+    if cfg.dialer != nil {
+        clientOptions.SetDialer(cfg.dialer)
+    }
+    var poolHandlers []func(*event.PoolEvent)
+    if cfg.observer != nil {
+        poolHandlers = append(poolHandlers, newPoolObserver(cfg.observer).handle)
+    }
+    if cfg.metrics != nil {
+        poolHandlers = append(poolHandlers, cfg.metrics.handlePoolEvent)
+    }
+    if len(poolHandlers) > 0 {
+        clientOptions.SetPoolMonitor(&event.PoolMonitor{Event: func(evt *event.PoolEvent) {
+            for _, handle := range poolHandlers {
+                handle(evt)
+            }
+        }})
+    }
+    client, err := connect(ctx, clientOptions, cfg)
+    if err != nil {
+        if cfg.observer != nil {
+            cfg.observer.OnError("", err)
+        }
+        return nil, err
+    }
+    if cfg.observer != nil {
+        for _, host := range clientOptions.Hosts {
+            cfg.observer.OnConnect(host)
+        }
+    }
+
+    database := client.Database(cfg.database)
+    collection := database.Collection(cfg.collection)
+    mindsClient := &MindsDBClient{client: client, collection: collection, fields: cfg.fields}
+    if cfg.auditCollection != "" {
+        mindsClient.audit = database.Collection(cfg.auditCollection)
+    }
+
+    mindsClient.shards = []*mongo.Collection{collection}
+    if cfg.shardCount > 1 {
+        mindsClient.shards = make([]*mongo.Collection, cfg.shardCount)
+        for i := range mindsClient.shards {
+            mindsClient.shards[i] = database.Collection(fmt.Sprintf("%s_%d", cfg.collection, i))
+        }
+    }
+
+    return mindsClient, nil
+}
+
+// withURIAuthSource fills in auth's empty AuthSource from the authSource
+// option of uri, which WithAuth would otherwise discard.
+func withURIAuthSource(auth options.Credential, uri string) options.Credential {
+    if auth.AuthSource != "" {
+        return auth
+    }
+    if _, query, ok := strings.Cut(uri, "?"); ok {
+        if values, err := url.ParseQuery(query); err == nil {
+            auth.AuthSource = values.Get("authSource")
+        }
+    }
+    return auth
+}
+
+// uriHost returns the host part of a MongoDB URI, leaving out any credentials.
+func uriHost(uri string) string {
+    u, err := url.Parse(uri)
+    if err != nil {
+        return "the given host"
+    }
+    return u.Host
+}
+
+// maxConnectRetryDelay caps the backoff between connection attempts.
+const maxConnectRetryDelay = 30 * time.Second
+
+// connect connects to MongoDB and pings it, retrying with exponential backoff
+// as configured by WithConnectRetries. It returns the last attempt's error if
+// every attempt fails or ctx is done first.
+func connect(ctx context.Context, clientOptions *options.ClientOptions, cfg clientConfig) (*mongo.Client, error) {
+    for attempt := 0; ; attempt++ {
+        client, err := connectOnce(ctx, clientOptions, cfg.connectTimeout)
+        if err == nil || attempt >= cfg.connectRetries {
+            return client, err
+        }
+
+        timer := time.NewTimer(retryDelay(cfg.retryBaseDelay, attempt))
+        select {
+        case <-ctx.Done():
+            timer.Stop()
+            return nil, err
+        case <-timer.C:
+        }
+    }
+}
+
+// connectOnce makes a single attempt to connect to MongoDB and ping it within timeout.
+func connectOnce(ctx context.Context, clientOptions *options.ClientOptions, timeout time.Duration) (*mongo.Client, error) {
     client, err := mongo.NewClient(clientOptions)
     if err != nil {
         return nil, fmt.Errorf("failed to create MongoDB client: %v", err)
     }
 
-    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    ctx, cancel := context.WithTimeout(ctx, timeout)
     defer cancel()
 
-    err = client.Connect(ctx)
-    if err != nil {
+    if err := client.Connect(ctx); err != nil {
         return nil, fmt.Errorf("failed to connect to MongoDB: %v", err)
     }
+    if err := client.Ping(ctx, nil); err != nil {
+        client.Disconnect(context.Background())
+        return nil, fmt.Errorf("failed to ping MongoDB: %v", err)
+    }
+    return client, nil
+}
 
-    collection := client.Database(dbName).Collection(collectionName)
+// retryDelay returns the wait before retry number attempt+1: base doubled for
+// each earlier attempt, capped at maxConnectRetryDelay, with the upper half
+// randomized so that clients started together don't retry in lockstep.
+func retryDelay(base time.Duration, attempt int) time.Duration {
+    delay := base
+    for i := 0; i < attempt && delay < maxConnectRetryDelay; i++ {
+        delay *= 2
+    }
+    delay = min(delay, maxConnectRetryDelay)
+    half := delay / 2
+    return half + time.Duration(rand.Int64N(int64(half)+1))
+}
 
-    return &MindsDBClient{collection: collection}, nil
+// shardFor returns the collection that holds predictors with the given name.
+func (client *MindsDBClient) shardFor(name string) *mongo.Collection {
+    if len(client.shards) == 1 {
+        return client.shards[0]
+    }
+    h := fnv.New32a()
+    h.Write([]byte(name))
+    return client.shards[h.Sum32()%uint32(len(client.shards))]
 }
 
-// CreatePredictor creates a new predictor in the MongoDB collection.
-func (client *MindsDBClient) CreatePredictor(predictor Predictor) error {
-    _, err := client.collection.InsertOne(context.TODO(), predictor)
-    return err
+// findPredictor looks up the predictor matching filter across all shards and
+// returns the document together with the collection holding it.
+func (client *MindsDBClient) findPredictor(ctx context.Context, filter bson.M) (*mongo.Collection, bson.Raw, error) {
+    for _, shard := range client.shards {
+        raw, err := shard.FindOne(ctx, filter).Raw()
+        if errors.Is(err, mongo.ErrNoDocuments) {
+            continue
+        }
+        if err != nil {
+            return nil, nil, fmt.Errorf("failed to find predictor: %v", err)
+        }
+        return shard, raw, nil
+    }
+    return nil, nil, ErrPredictorNotFound
 }
 
-// GetPredictors retrieves all predictors from the collection.
-func (client *MindsDBClient) GetPredictors() ([]Predictor, error) {
-    var predictors []Predictor
-    cursor, err := client.collection.Find(context.TODO(), bson.M{})
+// findPredictors decodes every predictor in collection matching filter.
+func (client *MindsDBClient) findPredictors(ctx context.Context, collection *mongo.Collection, filter interface{}, opts ...*options.FindOptions) ([]Predictor, error) {
+    cursor, err := collection.Find(ctx, filter, opts...)
     if err != nil {
         return nil, err
     }
-    defer cursor.Close(context.TODO())
+    defer cursor.Close(ctx)
 
-    for cursor.Next(context.TODO()) {
-        var predictor Predictor
-        err := cursor.Decode(&predictor)
+    var predictors []Predictor
+    for cursor.Next(ctx) {
+        predictor, err := client.decodePredictor(cursor.Current)
         if err != nil {
             return nil, err
         }
         predictors = append(predictors, predictor)
     }
-    return predictors, nil
+    return predictors, cursor.Err()
 }
 
-// CreatePredictorHandler handles the creation of a predictor via POST request.
-func CreatePredictorHandler(client *MindsDBClient, w http.ResponseWriter, r *http.Request) {
+// encodePredictor converts a predictor into a document using the configured field names.
+func (client *MindsDBClient) encodePredictor(predictor Predictor) bson.D {
+    doc := bson.D{}
+    if predictor.ID != "" {
+        doc = append(doc, bson.E{Key: "_id", Value: predictor.ID})
+    }
+    return append(doc, bson.E{Key: client.fields.Name, Value: predictor.Name})
+}
+
+// decodePredictor reads a predictor from a document using the configured field names.
+func (client *MindsDBClient) decodePredictor(raw bson.Raw) (Predictor, error) {
     var predictor Predictor
-    err := json.NewDecoder(r.Body).Decode(&predictor)
+
+    id, err := raw.LookupErr("_id")
     if err != nil {
-        http.Error(w, "Invalid input", http.StatusBadRequest)
+        return predictor, fmt.Errorf("document has no _id: %v", err)
+    }
+    switch id.Type {
+    case bson.TypeObjectID:
+        predictor.ID = id.ObjectID().Hex()
+    case bson.TypeString:
+        predictor.ID = id.StringValue()
+    default:
+        predictor.ID = id.String()
+    }
+
+    if name, err := raw.LookupErr(client.fields.Name); err == nil {
+        var ok bool
+        if predictor.Name, ok = name.StringValueOK(); !ok {
+            return predictor, fmt.Errorf("field %q is not a string", client.fields.Name)
+        }
+    }
+
+    return predictor, nil
+}
+
+// idString formats a document _id as returned by the driver.
+func idString(id interface{}) string {
+    switch v := id.(type) {
+    case primitive.ObjectID:
+        return v.Hex()
+    case string:
+        return v
+    default:
+        return fmt.Sprint(v)
+    }
+}
+
+// recordAudit writes an audit entry when auditing is enabled. Failures are logged
+// rather than returned because the predictor change has already been applied.
+func (client *MindsDBClient) recordAudit(ctx context.Context, operation, predictorID string) {
+    if client.audit == nil {
         return
     }
 
-    err = client.CreatePredictor(predictor)
+    user, _ := ctx.Value(auditUserKey{}).(string)
+    entry := AuditEntry{
+        Operation:   operation,
+        PredictorID: predictorID,
+        Timestamp:   time.Now().UTC(),
+        User:        user,
+    }
+    if _, err := client.audit.InsertOne(ctx, entry); err != nil {
+        logger.Error("Failed to write audit entry",
+            "request_id", RequestIDFromContext(ctx),
+            "predictor_id", predictorID,
+            "error", err,
+        )
+    }
+}
+
+// GetPredictorHistory returns the audit entries for a predictor, oldest first.
+func (client *MindsDBClient) GetPredictorHistory(ctx context.Context, id string) ([]AuditEntry, error) {
+    if client.audit == nil {
+        return nil, ErrAuditDisabled
+    }
+
+    opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}})
+    cursor, err := client.audit.Find(ctx, bson.M{"predictor_id": id}, opts)
     if err != nil {
-        http.Error(w, "Failed to create predictor", http.StatusInternalServerError)
-        return
+        return nil, fmt.Errorf("failed to query audit log: %v", err)
+    }
+    defer cursor.Close(ctx)
+
+    var entries []AuditEntry
+    if err := cursor.All(ctx, &entries); err != nil {
+        return nil, fmt.Errorf("failed to decode audit log: %v", err)
+    }
+    return entries, nil
+}
+
+// EnsureIndexes creates a unique index on the name field of every shard, so
+// that MongoDB rejects predictors with duplicate names. It does nothing for
+// indexes that already exist, but fails if existing documents share a name.
+func (client *MindsDBClient) EnsureIndexes(ctx context.Context) error {
+    index := mongo.IndexModel{
+        Keys:    bson.D{{Key: client.fields.Name, Value: 1}},
+        Options: options.Index().SetUnique(true),
+    }
+    for _, shard := range client.shards {
+        if _, err := shard.Indexes().CreateOne(ctx, index); err != nil {
+            return fmt.Errorf("failed to create name index on %s: %v", shard.Name(), err)
+        }
     }
+    return nil
+}
+
+// CreatePredictor creates a new predictor in the MongoDB collection and returns
+// its ID, the hex ObjectID MongoDB generated unless predictor.ID was set. It
+// returns ErrDuplicatePredictor if the name is taken and the unique index from
+// EnsureIndexes is in place.
+func (client *MindsDBClient) CreatePredictor(ctx context.Context, predictor Predictor) (string, error) {
+    var err error
+    if predictor.Name, err = normalizePredictorName(predictor.Name); err != nil {
+        return "", err
+    }
+
+    result, err := client.shardFor(predictor.Name).InsertOne(ctx, client.encodePredictor(predictor))
+    if mongo.IsDuplicateKeyError(err) {
+        return "", ErrDuplicatePredictor
+    }
+    if err != nil {
+        return "", err
+    }
+    id := idString(result.InsertedID)
+    client.recordAudit(ctx, AuditCreate, id)
+    return id, nil
+}
+
+// CreatePredictors inserts predictors in bulk and returns their generated hex IDs
+// in input order. The inserts are unordered, so one bad document doesn't abort
+// the batch; failures, including predictors that fail Validate, are reported
+// as a *BatchError and leave an empty ID at their index.
+func (client *MindsDBClient) CreatePredictors(ctx context.Context, predictors []Predictor) ([]string, error) {
+    ids := make([]string, len(predictors))
+    batchErr := &BatchError{Failed: make(map[int]error)}
+    docs := make(map[*mongo.Collection][]interface{})
+    indices := make(map[*mongo.Collection][]int)
+    for i, predictor := range predictors {
+        name, err := normalizePredictorName(predictor.Name)
+        if err != nil {
+            batchErr.Failed[i] = err
+            continue
+        }
+        predictor.Name = name
+
+        objID := primitive.NewObjectID()
+        ids[i] = objID.Hex()
+        doc := append(bson.D{{Key: "_id", Value: objID}}, client.encodePredictor(Predictor{Name: predictor.Name})...)
+
+        shard := client.shardFor(predictor.Name)
+        docs[shard] = append(docs[shard], doc)
+        indices[shard] = append(indices[shard], i)
+    }
+
+    opts := options.InsertMany().SetOrdered(false)
+    for shard, shardDocs := range docs {
+        _, err := shard.InsertMany(ctx, shardDocs, opts)
+        var bulkErr mongo.BulkWriteException
+        if errors.As(err, &bulkErr) && len(bulkErr.WriteErrors) > 0 {
+            for _, writeErr := range bulkErr.WriteErrors {
+                var failure error = writeErr
+                if mongo.IsDuplicateKeyError(writeErr) {
+                    failure = ErrDuplicatePredictor
+                }
+                batchErr.Failed[indices[shard][writeErr.Index]] = failure
+            }
+        } else if err != nil {
+            return nil, fmt.Errorf("failed to insert predictors: %v", err)
+        }
+    }
+
+    for i, id := range ids {
+        if _, failed := batchErr.Failed[i]; failed {
+            ids[i] = ""
+            continue
+        }
+        client.recordAudit(ctx, AuditCreate, id)
+    }
+
+    if len(batchErr.Failed) > 0 {
+        return ids, batchErr
+    }
+    return ids, nil
+}
+
+// idFilter builds a filter matching the predictor with the given hex ID.
+func idFilter(id string) (bson.M, error) {
+    objID, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return nil, ErrInvalidPredictorID
+    }
+    return bson.M{"_id": objID}, nil
+}
+
+// normalizePredictorName trims surrounding whitespace, so that " foo " and
+// "foo" are the same name, and checks the result with Validate. Every write
+// of a name goes through it.
+func normalizePredictorName(name string) (string, error) {
+    name = strings.TrimSpace(name)
+    if err := (Predictor{Name: name}).Validate(); err != nil {
+        return "", err
+    }
+    return name, nil
+}
+
+// RenamePredictor changes the name of the predictor with the given ID. It returns
+// ErrDuplicatePredictor if another predictor already uses the new name,
+// ErrPredictorNotFound if no predictor has the ID and a *ValidationError if
+// Validate rejects the name.
+func (client *MindsDBClient) RenamePredictor(ctx context.Context, id, newName string) error {
+    filter, err := idFilter(id)
+    if err != nil {
+        return err
+    }
+    newName, err = normalizePredictorName(newName)
+    if err != nil {
+        return err
+    }
+
+    // The unique index from EnsureIndexes rejects a taken name atomically;
+    // checking first would race with concurrent renames.
+    return client.setPredictorName(ctx, id, filter, newName)
+}
+
+// UpdatePredictor replaces the fields of the predictor with the given ID. It
+// returns ErrInvalidPredictorID if id is not a valid ObjectID,
+// ErrPredictorNotFound if no predictor has the ID and a *ValidationError if
+// the predictor fails Validate.
+func (client *MindsDBClient) UpdatePredictor(ctx context.Context, id string, predictor Predictor) error {
+    filter, err := idFilter(id)
+    if err != nil {
+        return err
+    }
+    name, err := normalizePredictorName(predictor.Name)
+    if err != nil {
+        return err
+    }
+    return client.setPredictorName(ctx, id, filter, name)
+}
+
+// UpsertOption changes how UpsertPredictors writes its batch.
+type UpsertOption func(*upsertConfig)
+
+type upsertConfig struct {
+    ordered bool
+}
+
+// OrderedUpsert makes UpsertPredictors write the predictors in input order
+// and stop at the first one that fails validation or its write. Predictors
+// before it stay applied; those after it are listed in BatchError.Skipped.
+func OrderedUpsert() UpsertOption {
+    return func(cfg *upsertConfig) {
+        cfg.ordered = true
+    }
+}
+
+// upsertBatch is one bulk write of UpsertPredictors. indices maps its write
+// models to their index in the input.
+type upsertBatch struct {
+    shard   *mongo.Collection
+    models  []mongo.WriteModel
+    indices []int
+}
+
+// UpsertPredictors inserts or updates predictors matched on name, and returns
+// how many were inserted and how many already existed. Predictor IDs are
+// ignored. By default the writes are unordered, one bulk write per shard, so a
+// failing predictor doesn't stop the rest; with OrderedUpsert they stop at the
+// first failure. Failures, including predictors that fail Validate, are
+// reported as a *BatchError alongside the counts of the writes that succeeded.
+func (client *MindsDBClient) UpsertPredictors(ctx context.Context, predictors []Predictor, opts ...UpsertOption) (inserted, updated int64, err error) {
+    var cfg upsertConfig
+    for _, opt := range opts {
+        opt(&cfg)
+    }
+
+    batchErr := &BatchError{Failed: make(map[int]error)}
+    names := make([]string, len(predictors))
+    var batches []*upsertBatch
+    for i, predictor := range predictors {
+        name, err := normalizePredictorName(predictor.Name)
+        if err != nil {
+            batchErr.Failed[i] = err
+            if cfg.ordered {
+                break
+            }
+            continue
+        }
+        names[i] = name
+
+        shard := client.shardFor(name)
+        model := mongo.NewUpdateOneModel().
+            SetFilter(bson.M{client.fields.Name: name}).
+            SetUpdate(bson.M{"$set": client.encodePredictor(Predictor{Name: name})}).
+            SetUpsert(true)
+        batch := findUpsertBatch(batches, shard, cfg.ordered)
+        if batch == nil {
+            batch = &upsertBatch{shard: shard}
+            batches = append(batches, batch)
+        }
+        batch.models = append(batch.models, model)
+        batch.indices = append(batch.indices, i)
+    }
+
+    writeOpts := options.BulkWrite().SetOrdered(cfg.ordered)
+    for _, batch := range batches {
+        result, err := batch.shard.BulkWrite(ctx, batch.models, writeOpts)
+
+        var bulkErr mongo.BulkWriteException
+        failed := errors.As(err, &bulkErr) && len(bulkErr.WriteErrors) > 0
+        if failed {
+            for _, writeErr := range bulkErr.WriteErrors {
+                batchErr.Failed[batch.indices[writeErr.Index]] = writeErr
+            }
+        } else if err != nil {
+            return inserted, updated, fmt.Errorf("failed to upsert predictors: %v", err)
+        }
+
+        // An ordered write stops at its failure, so later models never ran.
+        applied := batch.indices
+        if failed && cfg.ordered {
+            applied = applied[:bulkErr.WriteErrors[0].Index+1]
+        }
+        if result != nil {
+            inserted += result.UpsertedCount
+            updated += result.MatchedCount
+            client.auditUpserts(ctx, batch.shard, names, applied, result.UpsertedIDs, batchErr.Failed)
+        }
+        if failed && cfg.ordered {
+            break
+        }
+    }
+
+    if len(batchErr.Failed) == 0 {
+        return inserted, updated, nil
+    }
+    if cfg.ordered {
+        // Everything after the first failure was left unwritten.
+        first := len(predictors)
+        for i := range batchErr.Failed {
+            first = min(first, i)
+        }
+        for i := first + 1; i < len(predictors); i++ {
+            if batchErr.Failed[i] == nil {
+                batchErr.Skipped = append(batchErr.Skipped, i)
+            }
+        }
+    }
+    return inserted, updated, batchErr
+}
 
-    w.WriteHeader(http.StatusCreated)
-    json.NewEncoder(w).Encode(predictor)
+// findUpsertBatch returns the batch a write to shard joins: the shard's batch
+// for unordered writes, or the last batch if it is for the same shard when
+// ordered, since ordered writes may only be grouped while they stay in order.
+func findUpsertBatch(batches []*upsertBatch, shard *mongo.Collection, ordered bool) *upsertBatch {
+    if ordered {
+        if n := len(batches); n > 0 && batches[n-1].shard == shard {
+            return batches[n-1]
+        }
+        return nil
+    }
+    for _, batch := range batches {
+        if batch.shard == shard {
+            return batch
+        }
+    }
+    return nil
 }
 
-// GetPredictorsHandler handles retrieving the list of predictors via GET request.
-func GetPredictorsHandler(client *MindsDBClient, w http.ResponseWriter, r *http.Request) {
-    predictors, err := client.GetPredictors()
+// auditUpserts records an audit entry for each write of a shard's bulk upsert:
+// a create for each upserted ID and an update for each matched predictor,
+// whose ID is looked up by its unique name. indices maps the shard's write
+// models to their index in names.
+func (client *MindsDBClient) auditUpserts(ctx context.Context, shard *mongo.Collection, names []string, indices []int, upserted map[int64]interface{}, failed map[int]error) {
+    if client.audit == nil {
+        return
+    }
+
+    var matched []string
+    for n, i := range indices {
+        if id, ok := upserted[int64(n)]; ok {
+            client.recordAudit(ctx, AuditCreate, idString(id))
+        } else if failed[i] == nil {
+            matched = append(matched, names[i])
+        }
+    }
+    if len(matched) == 0 {
+        return
+    }
+
+    found, err := client.findPredictors(ctx, shard, bson.M{client.fields.Name: bson.M{"$in": matched}})
     if err != nil {
-        http.Error(w, "Failed to retrieve predictors", http.StatusInternalServerError)
+        logger.Error("Failed to look up upserted predictors for the audit log",
+            "request_id", RequestIDFromContext(ctx),
+            "error", err,
+        )
         return
     }
+    for _, predictor := range found {
+        client.recordAudit(ctx, AuditUpdate, predictor.ID)
+    }
+}
 
-    json.NewEncoder(w).Encode(predictors)
+// Close disconnects from MongoDB, waiting for in-progress operations to
+// finish until ctx is done.
+func (client *MindsDBClient) Close(ctx context.Context) error {
+    return client.client.Disconnect(ctx)
 }
 
-func main() {
-    // MongoDB Atlas connection string
-    uri := "mongodb+srv://<username>:<password>@cluster0.kpxtb.mongodb.net/<dbname>?retryWrites=true&w=majority"
+// Ping checks that the MongoDB deployment is reachable.
+func (client *MindsDBClient) Ping(ctx context.Context) error {
+    return client.client.Ping(ctx, nil)
+}
+
+// DeletePredictor removes the predictor with the given ID. It returns
+// ErrInvalidPredictorID if id is not a valid ObjectID and ErrPredictorNotFound
+// if no predictor has the ID.
+func (client *MindsDBClient) DeletePredictor(ctx context.Context, id string) error {
+    filter, err := idFilter(id)
+    if err != nil {
+        return err
+    }
+
+    for _, shard := range client.shards {
+        result, err := shard.DeleteOne(ctx, filter)
+        if err != nil {
+            return fmt.Errorf("failed to delete predictor: %v", err)
+        }
+        if result.DeletedCount > 0 {
+            client.recordAudit(ctx, AuditDelete, id)
+            return nil
+        }
+    }
+    return ErrPredictorNotFound
+}
+
+// DeletePredictorsByName removes every predictor whose name starts with
+// prefix, matched case-sensitively, on all shards and returns how many were
+// deleted. It returns ErrEmptyPrefix rather than deleting everything. With
+// auditing enabled the predictors are deleted one at a time, so that each
+// deleted ID gets an audit entry.
+func (client *MindsDBClient) DeletePredictorsByName(ctx context.Context, prefix string) (int64, error) {
+    if prefix == "" {
+        return 0, ErrEmptyPrefix
+    }
 
-    // Replace with your own credentials and database name
-    dbName := "mindsdb"
-    collectionName := "predictors"
+    filter := bson.M{client.fields.Name: bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}}
+    var deleted int64
+    for _, shard := range client.shards {
+        if client.audit != nil {
+            n, err := client.deleteAudited(ctx, shard, filter)
+            deleted += n
+            if err != nil {
+                return deleted, err
+            }
+            continue
+        }
+
+        result, err := shard.DeleteMany(ctx, filter)
+        if err != nil {
+            return deleted, fmt.Errorf("failed to delete predictors: %v", err)
+        }
+        deleted += result.DeletedCount
+    }
+    return deleted, nil
+}
 
-    client, err := NewMindsDBClient(uri, dbName, collectionName)
+// deleteAudited deletes the predictors in shard matching filter one by one,
+// recording an audit entry for each, and returns how many it deleted.
+// Predictors deleted or renamed concurrently by another caller are skipped.
+func (client *MindsDBClient) deleteAudited(ctx context.Context, shard *mongo.Collection, filter bson.M) (int64, error) {
+    found, err := client.findPredictors(ctx, shard, filter)
     if err != nil {
-        log.Fatalf("Failed to connect to MongoDB: %v", err)
+        return 0, fmt.Errorf("failed to find predictors to delete: %v", err)
     }
 
-    // Set up router
-    r := mux.NewRouter()
-    r.HandleFunc("/predictors", func(w http.ResponseWriter, r *http.Request) {
-        GetPredictorsHandler(client, w, r)
-    }).Methods("GET")
-    r.HandleFunc("/predictors", func(w http.ResponseWriter, r *http.Request) {
-        CreatePredictorHandler(client, w, r)
-    }).Methods("POST")
+    var deleted int64
+    for _, predictor := range found {
+        byID, err := idFilter(predictor.ID)
+        if err != nil {
+            byID = bson.M{"_id": predictor.ID}
+        }
+        // Keep the name condition in case the predictor was renamed meanwhile.
+        byID[client.fields.Name] = filter[client.fields.Name]
+        result, err := shard.DeleteOne(ctx, byID)
+        if err != nil {
+            return deleted, fmt.Errorf("failed to delete predictor: %v", err)
+        }
+        if result.DeletedCount > 0 {
+            deleted++
+            client.recordAudit(ctx, AuditDelete, predictor.ID)
+        }
+    }
+    return deleted, nil
+}
+
+// immutablePredictorFields are fields a patch may never change.
+var immutablePredictorFields = map[string]bool{"id": true, "_id": true, "created_at": true}
+
+// patchedName validates patch, which may only set "name", and returns the new
+// name. Problems are reported as a *ValidationError.
+func patchedName(patch map[string]interface{}) (string, error) {
+    if len(patch) == 0 {
+        return "", &ValidationError{Field: "patch", Message: "must set at least one field"}
+    }
+    for field := range patch {
+        if immutablePredictorFields[field] {
+            return "", &ValidationError{Field: field, Message: "cannot be changed"}
+        }
+        if field != "name" {
+            return "", &ValidationError{Field: field, Message: "is not a known field"}
+        }
+    }
+
+    name, ok := patch["name"].(string)
+    if !ok {
+        return "", &ValidationError{Field: "name", Message: "must be a string"}
+    }
+    return normalizePredictorName(name)
+}
 
-    log.Println("Server is running on port 8080")
-    log.Fatal(http.ListenAndServe(":8080", r))
+// PatchPredictor changes only the fields present in patch on the predictor with
+// the given ID. "name" is currently the only patchable field; unknown and
+// immutable fields such as "id" are rejected with a *ValidationError.
+func (client *MindsDBClient) PatchPredictor(ctx context.Context, id string, patch map[string]interface{}) error {
+    name, err := patchedName(patch)
+    if err != nil {
+        return err
+    }
+    return client.RenamePredictor(ctx, id, name)
+}
+
+// setPredictorName writes name to the predictor matching filter, moving it to
+// the shard for the new name when sharding is enabled.
+func (client *MindsDBClient) setPredictorName(ctx context.Context, id string, filter bson.M, name string) error {
+    target := client.shardFor(name)
+    if len(client.shards) > 1 {
+        source, raw, err := client.findPredictor(ctx, filter)
+        if err != nil {
+            return err
+        }
+        if source != target {
+            if err := client.movePredictor(ctx, source, target, filter, raw, name); err != nil {
+                return err
+            }
+            client.recordAudit(ctx, AuditUpdate, id)
+            return nil
+        }
+    }
+
+    result, err := target.UpdateOne(ctx, filter, bson.M{"$set": bson.M{client.fields.Name: name}})
+    if mongo.IsDuplicateKeyError(err) {
+        return ErrDuplicatePredictor
+    }
+    if err != nil {
+        return fmt.Errorf("failed to update predictor: %v", err)
+    }
+    if result.MatchedCount == 0 {
+        return ErrPredictorNotFound
+    }
+    client.recordAudit(ctx, AuditUpdate, id)
+    return nil
+}
+
+// movePredictor rewrites a renamed predictor into the shard for its new name.
+// The insert and delete are separate operations, so a rename that changes
+// shard is not atomic.
+func (client *MindsDBClient) movePredictor(ctx context.Context, source, target *mongo.Collection, filter bson.M, raw bson.Raw, newName string) error {
+    var doc bson.D
+    if err := bson.Unmarshal(raw, &doc); err != nil {
+        return fmt.Errorf("failed to decode predictor: %v", err)
+    }
+
+    renamed := false
+    for i := range doc {
+        if doc[i].Key == client.fields.Name {
+            doc[i].Value = newName
+            renamed = true
+        }
+    }
+    if !renamed {
+        doc = append(doc, bson.E{Key: client.fields.Name, Value: newName})
+    }
+
+    _, err := target.InsertOne(ctx, doc)
+    if mongo.IsDuplicateKeyError(err) {
+        return ErrDuplicatePredictor
+    }
+    if err != nil {
+        return fmt.Errorf("failed to move predictor: %v", err)
+    }
+    if _, err := source.DeleteOne(ctx, filter); err != nil {
+        return fmt.Errorf("failed to remove predictor from previous shard: %v", err)
+    }
+    return nil
+}
+
+// PredictorEventType is the kind of change reported in a PredictorEvent.
+type PredictorEventType string
+
+// Predictor event types.
+const (
+    PredictorInserted PredictorEventType = "insert"
+    PredictorUpdated  PredictorEventType = "update"
+    PredictorDeleted  PredictorEventType = "delete"
+)
+
+// PredictorEvent is a change to a predictor reported by WatchPredictors.
+type PredictorEvent struct {
+    Type PredictorEventType
+    ID   string
+    // Predictor is the document after the change. It is nil for deletes, and
+    // for updates to a predictor that was deleted before it could be read.
+    Predictor *Predictor
+}
+
+// watchRetryBaseDelay is the wait before the first attempt to resume an
+// interrupted change stream.
+const watchRetryBaseDelay = 500 * time.Millisecond
+
+// changeStreamsUnsupportedCode is the server error for a change stream opened
+// on a standalone server.
+const changeStreamsUnsupportedCode = 40573
+
+// WatchPredictors streams inserts, updates and deletes of predictors on every
+// shard until ctx is done, then closes both channels. The stream is open when
+// WatchPredictors returns, so every later change is reported. Change streams
+// need a replica set or sharded cluster; on a standalone server the error
+// channel receives ErrReplicaSetRequired. After network errors and other
+// transient failures the stream is reopened from the last event's resume
+// token, so no events are missed or repeated. If MongoDB closes the stream,
+// for example because the database was dropped, the error channel receives
+// ErrChangeStreamInvalidated. Any other error is sent on the error channel
+// and ends the stream. A rename that moves a predictor to another shard is
+// reported as an insert followed by a delete.
+func (client *MindsDBClient) WatchPredictors(ctx context.Context) (<-chan PredictorEvent, <-chan error) {
+    events := make(chan PredictorEvent)
+    errs := make(chan error, 1)
+
+    stream, err := client.openPredictorStream(ctx, nil)
+    go func() {
+        defer close(errs)
+        defer close(events)
+
+        var resumeToken bson.Raw
+        for attempt := 0; ; attempt++ {
+            if err == nil {
+                var progressed bool
+                progressed, err = client.streamPredictors(ctx, stream, &resumeToken, events)
+                stream.Close(context.Background())
+                if progressed {
+                    attempt = 0
+                }
+            }
+            switch {
+            case ctx.Err() != nil:
+                return
+            case err == nil:
+                errs <- ErrChangeStreamInvalidated
+                return
+            case !isResumableChangeStreamError(err):
+                errs <- err
+                return
+            }
+            logger.Error("Predictor change stream interrupted, resuming", "attempt", attempt+1, "error", err)
+
+            timer := time.NewTimer(retryDelay(watchRetryBaseDelay, attempt))
+            select {
+            case <-ctx.Done():
+                timer.Stop()
+                return
+            case <-timer.C:
+            }
+            stream, err = client.openPredictorStream(ctx, resumeToken)
+        }
+    }()
+
+    return events, errs
+}
+
+// openPredictorStream opens a change stream over the shards, resuming after
+// resumeToken if it is set.
+func (client *MindsDBClient) openPredictorStream(ctx context.Context, resumeToken bson.Raw) (*mongo.ChangeStream, error) {
+    names := make([]string, len(client.shards))
+    for i, shard := range client.shards {
+        names[i] = shard.Name()
+    }
+    pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{
+        "ns.coll":       bson.M{"$in": names},
+        "operationType": bson.M{"$in": []string{"insert", "update", "replace", "delete"}},
+    }}}}
+
+    opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+    if resumeToken != nil {
+        opts.SetResumeAfter(resumeToken)
+    }
+
+    // All shards live in one database, so a single stream covers them in order.
+    stream, err := client.collection.Database().Watch(ctx, pipeline, opts)
+    if err != nil {
+        var cmdErr mongo.CommandError
+        if errors.As(err, &cmdErr) && cmdErr.Code == changeStreamsUnsupportedCode {
+            return nil, ErrReplicaSetRequired
+        }
+        // Returned unwrapped so that WatchPredictors can tell whether to retry.
+        return nil, err
+    }
+    return stream, nil
+}
+
+// streamPredictors sends the events from stream until it ends, advancing
+// *resumeToken as they are delivered. It reports whether any event was
+// delivered, and returns nil if the server closed the stream.
+func (client *MindsDBClient) streamPredictors(ctx context.Context, stream *mongo.ChangeStream, resumeToken *bson.Raw, events chan<- PredictorEvent) (bool, error) {
+    progressed := false
+    for stream.Next(ctx) {
+        var change struct {
+            OperationType string `bson:"operationType"`
+            DocumentKey   struct {
+                ID interface{} `bson:"_id"`
+            } `bson:"documentKey"`
+            FullDocument bson.RawValue `bson:"fullDocument"`
+        }
+        if err := stream.Decode(&change); err != nil {
+            return progressed, fmt.Errorf("failed to decode predictor change: %v", err)
+        }
+
+        event := PredictorEvent{Type: PredictorUpdated, ID: idString(change.DocumentKey.ID)}
+        switch change.OperationType {
+        case "insert":
+            event.Type = PredictorInserted
+        case "delete":
+            event.Type = PredictorDeleted
+        }
+        if change.FullDocument.Type == bson.TypeEmbeddedDocument {
+            predictor, err := client.decodePredictor(change.FullDocument.Document())
+            if err != nil {
+                return progressed, err
+            }
+            event.Predictor = &predictor
+        }
+
+        select {
+        case events <- event:
+        case <-ctx.Done():
+            return progressed, ctx.Err()
+        }
+        *resumeToken = stream.ResumeToken()
+        progressed = true
+    }
+    if token := stream.ResumeToken(); token != nil {
+        *resumeToken = token
+    }
+    return progressed, stream.Err()
+}
+
+// isResumableChangeStreamError reports whether a change stream that failed
+// with err can be reopened from its resume token.
+func isResumableChangeStreamError(err error) bool {
+    if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+        return true
+    }
+    var serverErr mongo.ServerError
+    return errors.As(err, &serverErr) && serverErr.HasErrorLabel("ResumableChangeStreamError")
+}
+
+// GetPredictors retrieves all predictors, merging the results of every shard.
+func (client *MindsDBClient) GetPredictors(ctx context.Context) ([]Predictor, error) {
+    var predictors []Predictor
+    for _, shard := range client.shards {
+        found, err := client.findPredictors(ctx, shard, bson.M{})
+        if err != nil {
+            return nil, err
+        }
+        predictors = append(predictors, found...)
+    }
+    return predictors, nil
+}
+
+// CountPredictors returns the number of predictors across all shards.
+func (client *MindsDBClient) CountPredictors(ctx context.Context) (int64, error) {
+    var total int64
+    for _, shard := range client.shards {
+        count, err := shard.CountDocuments(ctx, bson.M{})
+        if err != nil {
+            return 0, fmt.Errorf("failed to count predictors: %v", err)
+        }
+        total += count
+    }
+    return total, nil
+}
+
+// EstimatePredictorCount returns the number of predictors from collection
+// metadata. It is much cheaper than CountPredictors but may be slightly stale,
+// for example after an unclean shutdown.
+func (client *MindsDBClient) EstimatePredictorCount(ctx context.Context) (int64, error) {
+    var total int64
+    for _, shard := range client.shards {
+        count, err := shard.EstimatedDocumentCount(ctx)
+        if err != nil {
+            return 0, fmt.Errorf("failed to estimate predictor count: %v", err)
+        }
+        total += count
+    }
+    return total, nil
+}
+
+// SearchPredictors returns up to limit predictors, starting at offset, whose
+// names contain query, ignoring case, sorted by name. query is matched
+// literally; regex metacharacters in it are escaped.
+func (client *MindsDBClient) SearchPredictors(ctx context.Context, query string, limit, offset int64) ([]Predictor, error) {
+    if limit <= 0 {
+        return nil, fmt.Errorf("limit must be positive, got %d", limit)
+    }
+    if offset < 0 {
+        return nil, fmt.Errorf("offset must not be negative, got %d", offset)
+    }
+
+    filter := bson.M{client.fields.Name: bson.M{"$regex": regexp.QuoteMeta(query), "$options": "i"}}
+    sortByName := bson.D{{Key: client.fields.Name, Value: 1}}
+    if len(client.shards) == 1 {
+        opts := options.Find().SetSort(sortByName).SetLimit(limit).SetSkip(offset)
+        predictors, err := client.findPredictors(ctx, client.shards[0], filter, opts)
+        if err != nil {
+            return nil, fmt.Errorf("failed to search predictors: %v", err)
+        }
+        return predictors, nil
+    }
+
+    // As in GetPredictorsPaged, read the first offset+limit matches of every
+    // shard and page through the merged result.
+    opts := options.Find().SetSort(sortByName).SetLimit(offset + limit)
+    var predictors []Predictor
+    for _, shard := range client.shards {
+        found, err := client.findPredictors(ctx, shard, filter, opts)
+        if err != nil {
+            return nil, fmt.Errorf("failed to search predictors: %v", err)
+        }
+        predictors = append(predictors, found...)
+    }
+    sort.Slice(predictors, func(i, j int) bool { return predictors[i].Name < predictors[j].Name })
+
+    if offset >= int64(len(predictors)) {
+        return nil, nil
+    }
+    return predictors[offset:min(offset+limit, int64(len(predictors)))], nil
+}
+
+// GetPredictorByID retrieves a single predictor. It returns ErrInvalidPredictorID
+// if id is not a valid ObjectID and ErrPredictorNotFound if no predictor has the ID.
+func (client *MindsDBClient) GetPredictorByID(ctx context.Context, id string) (*Predictor, error) {
+    filter, err := idFilter(id)
+    if err != nil {
+        return nil, err
+    }
+
+    _, raw, err := client.findPredictor(ctx, filter)
+    if err != nil {
+        return nil, err
+    }
+    predictor, err := client.decodePredictor(raw)
+    if err != nil {
+        return nil, err
+    }
+    return &predictor, nil
+}
+
+// GetPredictorsCursor returns up to limit predictors whose IDs sort after afterID,
+// in ID order, together with the cursor for the next page. An empty afterID
+// starts from the beginning; a page shorter than limit has an empty next cursor.
+func (client *MindsDBClient) GetPredictorsCursor(ctx context.Context, afterID string, limit int) ([]Predictor, string, error) {
+    if limit <= 0 {
+        return nil, "", fmt.Errorf("limit must be positive, got %d", limit)
+    }
+
+    filter := bson.M{}
+    if afterID != "" {
+        after, err := primitive.ObjectIDFromHex(afterID)
+        if err != nil {
+            return nil, "", ErrInvalidPredictorID
+        }
+        filter["_id"] = bson.M{"$gt": after}
+    }
+    opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit))
+
+    var predictors []Predictor
+    for _, shard := range client.shards {
+        found, err := client.findPredictors(ctx, shard, filter, opts)
+        if err != nil {
+            return nil, "", err
+        }
+        predictors = append(predictors, found...)
+    }
+
+    // Each shard returns its own first page; merge them and keep the overall first page.
+    sort.Slice(predictors, func(i, j int) bool { return predictors[i].ID < predictors[j].ID })
+    if len(predictors) > limit {
+        predictors = predictors[:limit]
+    }
+
+    next := ""
+    if len(predictors) == limit {
+        next = predictors[limit-1].ID
+    }
+    return predictors, next, nil
+}
+
+// GetPredictorsPaged returns up to limit predictors starting at offset, ordered
+// by ID so that consecutive pages don't overlap.
+func (client *MindsDBClient) GetPredictorsPaged(ctx context.Context, limit, offset int64) ([]Predictor, error) {
+    if limit <= 0 {
+        return nil, fmt.Errorf("limit must be positive, got %d", limit)
+    }
+    if offset < 0 {
+        return nil, fmt.Errorf("offset must not be negative, got %d", offset)
+    }
+
+    sortByID := bson.D{{Key: "_id", Value: 1}}
+    if len(client.shards) == 1 {
+        opts := options.Find().SetSort(sortByID).SetLimit(limit).SetSkip(offset)
+        return client.findPredictors(ctx, client.shards[0], bson.M{}, opts)
+    }
+
+    // Any shard may hold part of the page, so read the first offset+limit
+    // predictors of every shard and page through the merged result.
+    opts := options.Find().SetSort(sortByID).SetLimit(offset + limit)
+    var predictors []Predictor
+    for _, shard := range client.shards {
+        found, err := client.findPredictors(ctx, shard, bson.M{}, opts)
+        if err != nil {
+            return nil, err
+        }
+        predictors = append(predictors, found...)
+    }
+    sort.Slice(predictors, func(i, j int) bool { return predictors[i].ID < predictors[j].ID })
+
+    if offset >= int64(len(predictors)) {
+        return nil, nil
+    }
+    return predictors[offset:min(offset+limit, int64(len(predictors)))], nil
+}
+
+// writeJSON writes v as a JSON response body with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError writes an error response of the form
+// {"error": msg, "status": status}.
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+    writeJSON(w, status, struct {
+        Error  string `json:"error"`
+        Status int    `json:"status"`
+    }{msg, status})
+}
+
+// CreatePredictorHandler handles the creation of a predictor via POST request.
+func CreatePredictorHandler(store PredictorStore, w http.ResponseWriter, r *http.Request) {
+    var predictor Predictor
+    err := json.NewDecoder(r.Body).Decode(&predictor)
+    if err != nil {
+        writeJSONError(w, http.StatusBadRequest, "Invalid input")
+        return
+    }
+
+    predictor.ID, err = store.CreatePredictor(r.Context(), predictor)
+    var validationErr *ValidationError
+    if errors.As(err, &validationErr) {
+        writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+        return
+    }
+    if errors.Is(err, ErrDuplicatePredictor) {
+        writeJSONError(w, http.StatusConflict, err.Error())
+        return
+    }
+    if err != nil {
+        logRequestError(r, "Failed to create predictor", err)
+        writeJSONError(w, http.StatusInternalServerError, "Failed to create predictor")
+        return
+    }
+
+    // Report the name as stored, without the whitespace the store trimmed.
+    predictor.Name = strings.TrimSpace(predictor.Name)
+    writeJSON(w, http.StatusCreated, predictor)
+}
+
+const (
+    defaultPageLimit = 20
+    maxPageLimit     = 100
+)
+
+// parsePageLimit reads a page size query parameter, applying the default when
+// it is empty and capping it at maxPageLimit.
+func parsePageLimit(value string) (int, error) {
+    if value == "" {
+        return defaultPageLimit, nil
+    }
+    limit, err := strconv.Atoi(value)
+    if err != nil || limit < 1 {
+        return 0, fmt.Errorf("limit must be a positive integer")
+    }
+    if limit > maxPageLimit {
+        limit = maxPageLimit
+    }
+    return limit, nil
+}
+
+// CreatePredictorsHandler handles bulk creation of predictors via POST request.
+// It responds 201 when every predictor was stored and 207 with the per-index
+// errors when only some were.
+func CreatePredictorsHandler(store PredictorStore, w http.ResponseWriter, r *http.Request) {
+    var predictors []Predictor
+    err := json.NewDecoder(r.Body).Decode(&predictors)
+    if err != nil || len(predictors) == 0 {
+        writeJSONError(w, http.StatusBadRequest, "Invalid input")
+        return
+    }
+
+    ids, err := store.CreatePredictors(r.Context(), predictors)
+    var batchErr *BatchError
+    if err != nil && !errors.As(err, &batchErr) {
+        logRequestError(r, "Failed to create predictors", err)
+        writeJSONError(w, http.StatusInternalServerError, "Failed to create predictors")
+        return
+    }
+
+    response := struct {
+        IDs    []string          `json:"ids"`
+        Failed map[string]string `json:"failed,omitempty"`
+    }{IDs: ids}
+    status := http.StatusCreated
+    if batchErr != nil {
+        status = http.StatusMultiStatus
+        response.Failed = make(map[string]string, len(batchErr.Failed))
+        for i, failure := range batchErr.Failed {
+            response.Failed[strconv.Itoa(i)] = failure.Error()
+        }
+    }
+
+    writeJSON(w, status, response)
+}
+
+// parsePageOffset reads an offset query parameter, defaulting to zero.
+func parsePageOffset(value string) (int64, error) {
+    if value == "" {
+        return 0, nil
+    }
+    offset, err := strconv.ParseInt(value, 10, 64)
+    if err != nil || offset < 0 {
+        return 0, fmt.Errorf("offset must be a non-negative integer")
+    }
+    return offset, nil
+}
+
+// GetPredictorsHandler handles retrieving a page of predictors via GET request.
+// Pages are selected with ?limit= and ?offset=. Passing ?after= switches to
+// cursor pagination and sets the X-Next-Cursor header to the value to pass as
+// ?after= for the following page.
+func GetPredictorsHandler(store PredictorStore, w http.ResponseWriter, r *http.Request) {
+    query := r.URL.Query()
+    limit, err := parsePageLimit(query.Get("limit"))
+    if err != nil {
+        writeJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    if query.Has("search") {
+        offset, err := parsePageOffset(query.Get("offset"))
+        if err != nil {
+            writeJSONError(w, http.StatusBadRequest, err.Error())
+            return
+        }
+
+        predictors, err := store.SearchPredictors(r.Context(), query.Get("search"), int64(limit), offset)
+        if err != nil {
+            logRequestError(r, "Failed to search predictors", err)
+            writeJSONError(w, http.StatusInternalServerError, "Failed to search predictors")
+            return
+        }
+        writeJSON(w, http.StatusOK, predictors)
+        return
+    }
+
+    if query.Has("after") {
+        predictors, next, err := store.GetPredictorsCursor(r.Context(), query.Get("after"), limit)
+        if errors.Is(err, ErrInvalidPredictorID) {
+            writeJSONError(w, http.StatusBadRequest, "Invalid cursor")
+            return
+        }
+        if err != nil {
+            logRequestError(r, "Failed to retrieve predictors", err)
+            writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve predictors")
+            return
+        }
+
+        w.Header().Set("X-Next-Cursor", next)
+        writeJSON(w, http.StatusOK, predictors)
+        return
+    }
+
+    offset, err := parsePageOffset(query.Get("offset"))
+    if err != nil {
+        writeJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    predictors, err := store.GetPredictorsPaged(r.Context(), int64(limit), offset)
+    if err != nil {
+        logRequestError(r, "Failed to retrieve predictors", err)
+        writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve predictors")
+        return
+    }
+
+    writeJSON(w, http.StatusOK, predictors)
+}
+
+// CountPredictorsHandler handles counting predictors via GET request. With
+// ?estimate=true it returns a cheaper estimate that may be slightly stale.
+func CountPredictorsHandler(store PredictorStore, w http.ResponseWriter, r *http.Request) {
+    estimate := false
+    if value := r.URL.Query().Get("estimate"); value != "" {
+        var err error
+        if estimate, err = strconv.ParseBool(value); err != nil {
+            writeJSONError(w, http.StatusBadRequest, "estimate must be true or false")
+            return
+        }
+    }
+
+    count := store.CountPredictors
+    if estimate {
+        count = store.EstimatePredictorCount
+    }
+    n, err := count(r.Context())
+    if err != nil {
+        logRequestError(r, "Failed to count predictors", err)
+        writeJSONError(w, http.StatusInternalServerError, "Failed to count predictors")
+        return
+    }
+
+    writeJSON(w, http.StatusOK, map[string]int64{"count": n})
+}
+
+// GetPredictorHandler handles retrieving a single predictor via GET request.
+func GetPredictorHandler(store PredictorStore, w http.ResponseWriter, r *http.Request) {
+    predictor, err := store.GetPredictorByID(r.Context(), mux.Vars(r)["id"])
+    switch {
+    case errors.Is(err, ErrInvalidPredictorID):
+        writeJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    case errors.Is(err, ErrPredictorNotFound):
+        writeJSONError(w, http.StatusNotFound, err.Error())
+        return
+    case err != nil:
+        logRequestError(r, "Failed to retrieve predictor", err)
+        writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve predictor")
+        return
+    }
+
+    writeJSON(w, http.StatusOK, predictor)
+}
+
+// UpdatePredictorHandler handles updating a predictor via PUT request.
+func UpdatePredictorHandler(store PredictorStore, w http.ResponseWriter, r *http.Request) {
+    var predictor Predictor
+    err := json.NewDecoder(r.Body).Decode(&predictor)
+    if err != nil {
+        writeJSONError(w, http.StatusBadRequest, "Invalid input")
+        return
+    }
+
+    id := mux.Vars(r)["id"]
+    err = store.UpdatePredictor(r.Context(), id, predictor)
+    var validationErr *ValidationError
+    switch {
+    case errors.As(err, &validationErr):
+        writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+        return
+    case errors.Is(err, ErrInvalidPredictorID):
+        writeJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    case errors.Is(err, ErrPredictorNotFound):
+        writeJSONError(w, http.StatusNotFound, err.Error())
+        return
+    case errors.Is(err, ErrDuplicatePredictor):
+        writeJSONError(w, http.StatusConflict, err.Error())
+        return
+    case err != nil:
+        logRequestError(r, "Failed to update predictor", err)
+        writeJSONError(w, http.StatusInternalServerError, "Failed to update predictor")
+        return
+    }
+
+    predictor.ID = id
+    writeJSON(w, http.StatusOK, predictor)
+}
+
+// DeletePredictorHandler handles deleting a predictor via DELETE request.
+func DeletePredictorHandler(store PredictorStore, w http.ResponseWriter, r *http.Request) {
+    err := store.DeletePredictor(r.Context(), mux.Vars(r)["id"])
+    switch {
+    case errors.Is(err, ErrInvalidPredictorID):
+        writeJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    case errors.Is(err, ErrPredictorNotFound):
+        writeJSONError(w, http.StatusNotFound, err.Error())
+        return
+    case err != nil:
+        logRequestError(r, "Failed to delete predictor", err)
+        writeJSONError(w, http.StatusInternalServerError, "Failed to delete predictor")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// DeletePredictorsHandler handles deleting every predictor whose name starts
+// with the prefix query parameter via DELETE request.
+func DeletePredictorsHandler(store PredictorStore, w http.ResponseWriter, r *http.Request) {
+    deleted, err := store.DeletePredictorsByName(r.Context(), r.URL.Query().Get("prefix"))
+    switch {
+    case errors.Is(err, ErrEmptyPrefix):
+        writeJSONError(w, http.StatusBadRequest, "prefix query parameter is required")
+        return
+    case err != nil:
+        logRequestError(r, "Failed to delete predictors", err)
+        writeJSONError(w, http.StatusInternalServerError, "Failed to delete predictors")
+        return
+    }
+
+    writeJSON(w, http.StatusOK, map[string]int64{"deleted": deleted})
+}
+
+// RenamePredictorHandler handles renaming a predictor via PATCH request.
+func RenamePredictorHandler(store PredictorStore, w http.ResponseWriter, r *http.Request) {
+    var body struct {
+        Name string `json:"name"`
+    }
+    err := json.NewDecoder(r.Body).Decode(&body)
+    if err != nil {
+        writeJSONError(w, http.StatusBadRequest, "Invalid input")
+        return
+    }
+
+    err = store.RenamePredictor(r.Context(), mux.Vars(r)["id"], body.Name)
+    var validationErr *ValidationError
+    switch {
+    case errors.As(err, &validationErr):
+        writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+        return
+    case errors.Is(err, ErrInvalidPredictorID):
+        writeJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    case errors.Is(err, ErrPredictorNotFound):
+        writeJSONError(w, http.StatusNotFound, err.Error())
+        return
+    case errors.Is(err, ErrDuplicatePredictor):
+        writeJSONError(w, http.StatusConflict, err.Error())
+        return
+    case err != nil:
+        logRequestError(r, "Failed to rename predictor", err)
+        writeJSONError(w, http.StatusInternalServerError, "Failed to rename predictor")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// PatchPredictorHandler handles partially updating a predictor via PATCH request.
+func PatchPredictorHandler(store PredictorStore, w http.ResponseWriter, r *http.Request) {
+    var patch map[string]interface{}
+    if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+        writeJSONError(w, http.StatusBadRequest, "Invalid input")
+        return
+    }
+
+    err := store.PatchPredictor(r.Context(), mux.Vars(r)["id"], patch)
+    var validationErr *ValidationError
+    switch {
+    case errors.As(err, &validationErr):
+        writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+        return
+    case errors.Is(err, ErrInvalidPredictorID):
+        writeJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    case errors.Is(err, ErrPredictorNotFound):
+        writeJSONError(w, http.StatusNotFound, err.Error())
+        return
+    case errors.Is(err, ErrDuplicatePredictor):
+        writeJSONError(w, http.StatusConflict, err.Error())
+        return
+    case err != nil:
+        logRequestError(r, "Failed to patch predictor", err)
+        writeJSONError(w, http.StatusInternalServerError, "Failed to patch predictor")
+        return
+    }
+
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// healthCheckTimeout bounds how long HealthHandler waits for the backend, so a
+// dead database fails the probe instead of hanging it.
+const healthCheckTimeout = 2 * time.Second
+
+// HealthHandler reports whether the backend is reachable, for use as a
+// load balancer readiness probe.
+func HealthHandler(store PredictorStore, w http.ResponseWriter, r *http.Request) {
+    ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+    defer cancel()
+
+    if err := store.Ping(ctx); err != nil {
+        writeJSONError(w, http.StatusServiceUnavailable, err.Error())
+        return
+    }
+
+    writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// shutdownTimeout is how long in-flight requests get to finish after the
+// server is told to stop.
+const shutdownTimeout = 15 * time.Second
+
+func main() {
+    cfg, err := LoadConfigFromEnv()
+    if err != nil {
+        logger.Error("Failed to load configuration", "error", err)
+        os.Exit(1)
+    }
+
+    registry := prometheus.NewRegistry()
+    registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+    metrics, err := NewMetrics(registry)
+    if err != nil {
+        logger.Error("Failed to register metrics", "error", err)
+        os.Exit(1)
+    }
+
+    var store PredictorStore
+    if cfg.MySQLDSN != "" {
+        // MindsDB speaks the MySQL wire protocol, e.g. "root@tcp(localhost:47334)/mindsdb?timeout=10s"
+        mysqlStore, err := NewMySQLStore(cfg.MySQLDSN)
+        if err != nil {
+            logger.Error("Error creating MindsDB client", "error", err)
+            os.Exit(1)
+        }
+
+        if err := mysqlStore.CreatePredictorsTable(); err != nil {
+            mysqlStore.Close(context.Background())
+            logger.Error("Error creating predictors table", "error", err)
+            os.Exit(1)
+        }
+        store = mysqlStore
+    } else {
+        opts := []ClientOption{WithDatabase(cfg.Database), WithCollection(cfg.Collection), WithMetrics(metrics)}
+        if cfg.MongoUsername != "" {
+            opts = append(opts, WithAuth(cfg.MongoUsername, cfg.MongoPassword, cfg.MongoAuthSource))
+        }
+
+        client, err := NewMindsDBClient(cfg.MongoURI, opts...)
+        if err != nil {
+            logger.Error("Failed to connect to MongoDB", "error", err)
+            os.Exit(1)
+        }
+        if err := client.EnsureIndexes(context.Background()); err != nil {
+            client.Close(context.Background())
+            logger.Error("Failed to create indexes", "error", err)
+            os.Exit(1)
+        }
+        store = client
+    }
+
+    // Set up router
+    r := mux.NewRouter()
+    r.HandleFunc("/predictors", func(w http.ResponseWriter, r *http.Request) {
+        GetPredictorsHandler(store, w, r)
+    }).Methods("GET")
+    r.HandleFunc("/predictors", func(w http.ResponseWriter, r *http.Request) {
+        CreatePredictorHandler(store, w, r)
+    }).Methods("POST")
+    r.HandleFunc("/predictors", func(w http.ResponseWriter, r *http.Request) {
+        DeletePredictorsHandler(store, w, r)
+    }).Methods("DELETE")
+    r.HandleFunc("/predictors/batch", func(w http.ResponseWriter, r *http.Request) {
+        CreatePredictorsHandler(store, w, r)
+    }).Methods("POST")
+    r.HandleFunc("/predictors/count", func(w http.ResponseWriter, r *http.Request) {
+        CountPredictorsHandler(store, w, r)
+    }).Methods("GET")
+    r.HandleFunc("/predictors/{id}", func(w http.ResponseWriter, r *http.Request) {
+        GetPredictorHandler(store, w, r)
+    }).Methods("GET")
+    r.HandleFunc("/predictors/{id}", func(w http.ResponseWriter, r *http.Request) {
+        UpdatePredictorHandler(store, w, r)
+    }).Methods("PUT")
+    r.HandleFunc("/predictors/{id}", func(w http.ResponseWriter, r *http.Request) {
+        DeletePredictorHandler(store, w, r)
+    }).Methods("DELETE")
+    r.HandleFunc("/predictors/{id}", func(w http.ResponseWriter, r *http.Request) {
+        PatchPredictorHandler(store, w, r)
+    }).Methods("PATCH")
+    r.HandleFunc("/predictors/{id}/rename", func(w http.ResponseWriter, r *http.Request) {
+        RenamePredictorHandler(store, w, r)
+    }).Methods("PATCH")
+    r.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+        HealthHandler(store, w, r)
+    }).Methods("GET")
+    r.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{})).Methods("GET")
+    r.Use(MetricsMiddleware(metrics))
+
+    // Same-origin only unless MINDSDB_CORS_ORIGINS lists frontend origins.
+    // Logging wraps CORS so that rejected cross-origin requests are logged too.
+    handler := RequestLoggingMiddleware(TimeoutMiddleware(cfg.RequestTimeout)(CORSMiddleware(cfg.CORS)(r)))
+
+    server := &http.Server{Addr: cfg.ListenAddr, Handler: handler}
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    go func() {
+        logger.Info("Server is running", "addr", server.Addr)
+        if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+            logger.Error("Server failed", "error", err)
+            os.Exit(1)
+        }
+    }()
+
+    <-ctx.Done()
+    stop()
+    logger.Info("Shutting down")
+
+    // Let in-flight requests finish before closing the database connection.
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+    defer cancel()
+    if err := server.Shutdown(shutdownCtx); err != nil {
+        logger.Error("Error shutting down server", "error", err)
+    }
+    if err := store.Close(shutdownCtx); err != nil {
+        logger.Error("Error closing database connection", "error", err)
+    }
 }
@@ -0,0 +1,84 @@
+//go:build integration
+
+package main
+
+import (
+    "context"
+    "os"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newIntegrationClient connects to the MongoDB at MINDSDB_TEST_MONGO_URI, which
+// must be a replica set for change streams, using a collection unique to the
+// test that is dropped afterwards.
+func newIntegrationClient(t *testing.T, opts ...ClientOption) *MindsDBClient {
+    t.Helper()
+    uri := os.Getenv("MINDSDB_TEST_MONGO_URI")
+    if uri == "" {
+        t.Skip("MINDSDB_TEST_MONGO_URI is not set")
+    }
+
+    collection := "predictors_test_" + primitive.NewObjectID().Hex()
+    opts = append([]ClientOption{WithDatabase("mindsdb_test"), WithCollection(collection)}, opts...)
+    client, err := NewMindsDBClient(uri, opts...)
+    if err != nil {
+        t.Fatalf("NewMindsDBClient: %v", err)
+    }
+    t.Cleanup(func() {
+        ctx := context.Background()
+        client.collection.Drop(ctx)
+        client.Close(ctx)
+    })
+    return client
+}
+
+func TestIntegrationWatchPredictors(t *testing.T) {
+    client := newIntegrationClient(t)
+    ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+    defer cancel()
+
+    events, errs := client.WatchPredictors(ctx)
+    if _, err := client.CreatePredictor(ctx, Predictor{Name: "watched"}); err != nil {
+        t.Fatalf("CreatePredictor: %v", err)
+    }
+
+    select {
+    case event := <-events:
+        if event.Type != PredictorInserted || event.Predictor == nil || event.Predictor.Name != "watched" {
+            t.Errorf("event = %+v, want an insert of watched", event)
+        }
+    case err := <-errs:
+        t.Fatalf("WatchPredictors: %v", err)
+    case <-ctx.Done():
+        t.Fatal("timed out waiting for the insert event")
+    }
+}
+
+func TestIntegrationOnConnect(t *testing.T) {
+    recorder := &recordingObserver{}
+    client := newIntegrationClient(t, WithConnectionObserver(recorder))
+    if err := client.Ping(context.Background()); err != nil {
+        t.Fatalf("Ping: %v", err)
+    }
+
+    hosts := options.Client().ApplyURI(os.Getenv("MINDSDB_TEST_MONGO_URI")).Hosts
+    var want []string
+    for _, host := range hosts {
+        want = append(want, "connect "+host)
+    }
+    var connects []string
+    for _, call := range recorder.Calls() {
+        if strings.HasPrefix(call, "connect ") {
+            connects = append(connects, call)
+        }
+    }
+    if !reflect.DeepEqual(connects, want) {
+        t.Errorf("OnConnect calls = %q, want %q", connects, want)
+    }
+}
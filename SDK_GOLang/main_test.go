@@ -0,0 +1,653 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "strings"
+    "testing"
+
+    "github.com/DATA-DOG/go-sqlmock"
+    "github.com/gorilla/mux"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/integration/mtest"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newMockClient returns a MindsDBClient on the mock deployment of mt, with
+// one shard per collection in shards, or just mt.Coll if there are none.
+func newMockClient(mt *mtest.T, shards ...string) *MindsDBClient {
+    client := &MindsDBClient{client: mt.Client, collection: mt.Coll, shards: []*mongo.Collection{mt.Coll}, fields: DefaultFieldMap}
+    if len(shards) > 0 {
+        client.shards = make([]*mongo.Collection, len(shards))
+        for i, name := range shards {
+            client.shards[i] = mt.DB.Collection(name)
+        }
+    }
+    return client
+}
+
+func TestNormalizePredictorName(t *testing.T) {
+    tests := []struct {
+        name    string
+        want    string
+        wantErr bool
+    }{
+        {"foo", "foo", false},
+        {"  foo  ", "foo", false},
+        {"", "", true},
+        {"   ", "", true},
+        {strings.Repeat("a", maxPredictorNameLength), strings.Repeat("a", maxPredictorNameLength), false},
+        {strings.Repeat("a", maxPredictorNameLength+1), "", true},
+        // Length is counted in characters, not bytes.
+        {strings.Repeat("é", maxPredictorNameLength), strings.Repeat("é", maxPredictorNameLength), false},
+    }
+    for _, tt := range tests {
+        got, err := normalizePredictorName(tt.name)
+        var validationErr *ValidationError
+        if tt.wantErr != errors.As(err, &validationErr) {
+            t.Errorf("normalizePredictorName(%.10q) error = %v, want ValidationError %v", tt.name, err, tt.wantErr)
+        }
+        if got != tt.want {
+            t.Errorf("normalizePredictorName(%.10q) = %.10q, want %.10q", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestNameWritesRejectInvalidNames(t *testing.T) {
+    routes := []struct {
+        method, path, pattern string
+        handler               func(PredictorStore, http.ResponseWriter, *http.Request)
+    }{
+        {"POST", "/predictors", "/predictors", CreatePredictorHandler},
+        {"PUT", "/predictors/1", "/predictors/{id}", UpdatePredictorHandler},
+        {"PATCH", "/predictors/1", "/predictors/{id}", PatchPredictorHandler},
+        {"PATCH", "/predictors/1/rename", "/predictors/{id}/rename", RenamePredictorHandler},
+    }
+    bodies := []string{`{"name":""}`, `{"name":"   "}`, `{"name":"` + strings.Repeat("a", 1000) + `"}`}
+
+    for _, route := range routes {
+        for _, body := range bodies {
+            // The mock expects no queries, so reaching the database fails the test.
+            store, _ := newMockStore(t)
+            r := mux.NewRouter()
+            r.HandleFunc(route.pattern, func(w http.ResponseWriter, r *http.Request) {
+                route.handler(store, w, r)
+            })
+
+            rec := httptest.NewRecorder()
+            r.ServeHTTP(rec, httptest.NewRequest(route.method, route.path, strings.NewReader(body)))
+            if rec.Code != http.StatusUnprocessableEntity {
+                t.Errorf("%s %s with %.20s = %d, want 422", route.method, route.path, body, rec.Code)
+            }
+        }
+    }
+}
+
+func TestWatchPredictors(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+    mt.Run("invalidated", func(mt *mtest.T) {
+        change := bson.D{
+            {Key: "_id", Value: bson.D{{Key: "_data", Value: "1"}}},
+            {Key: "operationType", Value: "insert"},
+            {Key: "documentKey", Value: bson.D{{Key: "_id", Value: "p1"}}},
+            {Key: "fullDocument", Value: bson.D{{Key: "_id", Value: "p1"}, {Key: "name", Value: "churn"}}},
+        }
+        mt.AddMockResponses(
+            mtest.CreateCursorResponse(1, "test.$cmd.aggregate", mtest.FirstBatch, change),
+            // A cursor ID of zero is the server closing the stream.
+            mtest.CreateCursorResponse(0, "test.$cmd.aggregate", mtest.NextBatch),
+        )
+
+        events, errs := newMockClient(mt).WatchPredictors(context.Background())
+        var got []PredictorEvent
+        for event := range events {
+            got = append(got, event)
+        }
+        if len(got) != 1 || got[0].Type != PredictorInserted || got[0].ID != "p1" || got[0].Predictor == nil || got[0].Predictor.Name != "churn" {
+            t.Errorf("events = %+v, want one insert of p1 named churn", got)
+        }
+        if err := <-errs; !errors.Is(err, ErrChangeStreamInvalidated) {
+            t.Errorf("error = %v, want ErrChangeStreamInvalidated", err)
+        }
+    })
+
+    mt.Run("standalone", func(mt *mtest.T) {
+        mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{
+            Code:    changeStreamsUnsupportedCode,
+            Message: "The $changeStream stage is only supported on replica sets",
+        }))
+
+        events, errs := newMockClient(mt).WatchPredictors(context.Background())
+        if _, ok := <-events; ok {
+            t.Error("received an event, want none")
+        }
+        if err := <-errs; !errors.Is(err, ErrReplicaSetRequired) {
+            t.Errorf("error = %v, want ErrReplicaSetRequired", err)
+        }
+    })
+}
+
+// auditedOperations returns the operation and predictor ID of every audit
+// entry mt's client inserted, in order.
+func auditedOperations(mt *mtest.T) []string {
+    var ops []string
+    for _, evt := range mt.GetAllStartedEvents() {
+        if evt.CommandName != "insert" || evt.Command.Lookup("insert").StringValue() != "audit" {
+            continue
+        }
+        docs, _ := evt.Command.Lookup("documents").Array().Values()
+        for _, doc := range docs {
+            entry := doc.Document()
+            ops = append(ops, entry.Lookup("operation").StringValue()+" "+entry.Lookup("predictor_id").StringValue())
+        }
+    }
+    return ops
+}
+
+func TestAudit(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    first, second := primitive.NewObjectID(), primitive.NewObjectID()
+
+    mt.Run("update writes one entry", func(mt *mtest.T) {
+        client := newMockClient(mt)
+        client.audit = mt.DB.Collection("audit")
+        mt.AddMockResponses(
+            mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+            mtest.CreateSuccessResponse(),
+        )
+
+        if err := client.UpdatePredictor(context.Background(), first.Hex(), Predictor{Name: "renamed"}); err != nil {
+            t.Fatalf("UpdatePredictor: %v", err)
+        }
+        if got, want := auditedOperations(mt), []string{"update " + first.Hex()}; !reflect.DeepEqual(got, want) {
+            t.Errorf("audit entries = %v, want %v", got, want)
+        }
+    })
+
+    mt.Run("prefix delete writes one entry per predictor", func(mt *mtest.T) {
+        client := newMockClient(mt)
+        client.audit = mt.DB.Collection("audit")
+        mt.AddMockResponses(
+            mtest.CreateCursorResponse(0, "test.predictors", mtest.FirstBatch,
+                bson.D{{Key: "_id", Value: first}, {Key: "name", Value: "test_a"}},
+                bson.D{{Key: "_id", Value: second}, {Key: "name", Value: "test_b"}}),
+            mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+            mtest.CreateSuccessResponse(),
+            mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+            mtest.CreateSuccessResponse(),
+        )
+
+        deleted, err := client.DeletePredictorsByName(context.Background(), "test_")
+        if err != nil || deleted != 2 {
+            t.Fatalf("DeletePredictorsByName = %d, %v, want 2, nil", deleted, err)
+        }
+        want := []string{"delete " + first.Hex(), "delete " + second.Hex()}
+        if got := auditedOperations(mt); !reflect.DeepEqual(got, want) {
+            t.Errorf("audit entries = %v, want %v", got, want)
+        }
+    })
+
+    mt.Run("upsert writes creates and updates", func(mt *mtest.T) {
+        client := newMockClient(mt)
+        client.audit = mt.DB.Collection("audit")
+        mt.AddMockResponses(
+            mtest.CreateSuccessResponse(
+                bson.E{Key: "n", Value: 2},
+                bson.E{Key: "nModified", Value: 1},
+                bson.E{Key: "upserted", Value: bson.A{bson.D{{Key: "index", Value: 0}, {Key: "_id", Value: first}}}},
+            ),
+            mtest.CreateSuccessResponse(),
+            mtest.CreateCursorResponse(0, "test.predictors", mtest.FirstBatch,
+                bson.D{{Key: "_id", Value: second}, {Key: "name", Value: "existing"}}),
+            mtest.CreateSuccessResponse(),
+        )
+
+        inserted, updated, err := client.UpsertPredictors(context.Background(), []Predictor{{Name: "new"}, {Name: "existing"}})
+        if err != nil || inserted != 1 || updated != 1 {
+            t.Fatalf("UpsertPredictors = %d, %d, %v, want 1, 1, nil", inserted, updated, err)
+        }
+        want := []string{"create " + first.Hex(), "update " + second.Hex()}
+        if got := auditedOperations(mt); !reflect.DeepEqual(got, want) {
+            t.Errorf("audit entries = %v, want %v", got, want)
+        }
+    })
+}
+
+func TestRenamePredictor(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    id := primitive.NewObjectID().Hex()
+
+    mt.Run("success", func(mt *mtest.T) {
+        mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+        if err := newMockClient(mt).RenamePredictor(context.Background(), id, "churn"); err != nil {
+            t.Errorf("RenamePredictor: %v", err)
+        }
+    })
+
+    mt.Run("conflict", func(mt *mtest.T) {
+        mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+            Code:    11000,
+            Message: "E11000 duplicate key error collection: test.predictors index: name_1",
+        }))
+        err := newMockClient(mt).RenamePredictor(context.Background(), id, "churn")
+        if !errors.Is(err, ErrDuplicatePredictor) {
+            t.Errorf("RenamePredictor error = %v, want ErrDuplicatePredictor", err)
+        }
+    })
+
+    mt.Run("not found", func(mt *mtest.T) {
+        mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}))
+        err := newMockClient(mt).RenamePredictor(context.Background(), id, "churn")
+        if !errors.Is(err, ErrPredictorNotFound) {
+            t.Errorf("RenamePredictor error = %v, want ErrPredictorNotFound", err)
+        }
+    })
+}
+
+func TestPatchedName(t *testing.T) {
+    tests := []struct {
+        patch     map[string]interface{}
+        want      string
+        wantField string
+    }{
+        {map[string]interface{}{"name": " churn "}, "churn", ""},
+        {map[string]interface{}{}, "", "patch"},
+        {map[string]interface{}{"_id": "abc"}, "", "_id"},
+        {map[string]interface{}{"created_at": "2024-01-01"}, "", "created_at"},
+        {map[string]interface{}{"name": "churn", "created_at": "2024-01-01"}, "", "created_at"},
+        {map[string]interface{}{"name": "churn", "owner": "me"}, "", "owner"},
+        {map[string]interface{}{"name": 42}, "", "name"},
+    }
+    for _, tt := range tests {
+        got, err := patchedName(tt.patch)
+        var validationErr *ValidationError
+        switch {
+        case tt.wantField == "" && err != nil:
+            t.Errorf("patchedName(%v) error = %v", tt.patch, err)
+        case tt.wantField != "" && (!errors.As(err, &validationErr) || validationErr.Field != tt.wantField):
+            t.Errorf("patchedName(%v) error = %v, want ValidationError on %s", tt.patch, err, tt.wantField)
+        case got != tt.want:
+            t.Errorf("patchedName(%v) = %q, want %q", tt.patch, got, tt.want)
+        }
+    }
+}
+
+func TestPatchPredictorSetsOnlyName(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+    mt.Run("name only", func(mt *mtest.T) {
+        mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+        id := primitive.NewObjectID().Hex()
+        if err := newMockClient(mt).PatchPredictor(context.Background(), id, map[string]interface{}{"name": "churn"}); err != nil {
+            t.Fatalf("PatchPredictor: %v", err)
+        }
+
+        update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Document()
+        set, ok := update.Lookup("$set").DocumentOK()
+        if !ok {
+            t.Fatalf("update = %v, want a $set", update)
+        }
+        elems, _ := set.Elements()
+        if len(elems) != 1 || set.Lookup("name").StringValue() != "churn" {
+            t.Errorf("$set = %v, want only name", set)
+        }
+    })
+}
+
+func TestShardFor(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+    mt.Run("deterministic", func(mt *mtest.T) {
+        shards := []string{"predictors_0", "predictors_1", "predictors_2"}
+        first, second := newMockClient(mt, shards...), newMockClient(mt, shards...)
+        for _, name := range []string{"churn", "home_rentals", "sales"} {
+            if a, b := first.shardFor(name).Name(), second.shardFor(name).Name(); a != b {
+                t.Errorf("shardFor(%q) = %s and %s, want the same shard", name, a, b)
+            }
+        }
+        // Existing documents stay where they are, so the hash must not change.
+        if got := first.shardFor("churn").Name(); got != "predictors_2" {
+            t.Errorf("shardFor(churn) = %s, want predictors_2", got)
+        }
+    })
+
+    mt.Run("create and get", func(mt *mtest.T) {
+        client := newMockClient(mt, "predictors_0", "predictors_1", "predictors_2")
+        id := primitive.NewObjectID()
+        ns := mt.DB.Name() + ".predictors_2"
+        mt.AddMockResponses(
+            mtest.CreateSuccessResponse(),
+            mtest.CreateCursorResponse(0, mt.DB.Name()+".predictors_0", mtest.FirstBatch),
+            mtest.CreateCursorResponse(0, mt.DB.Name()+".predictors_1", mtest.FirstBatch),
+            mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, bson.D{{Key: "_id", Value: id}, {Key: "name", Value: "churn"}}),
+        )
+
+        if _, err := client.CreatePredictor(context.Background(), Predictor{ID: id.Hex(), Name: "churn"}); err != nil {
+            t.Fatalf("CreatePredictor: %v", err)
+        }
+        if got := mt.GetStartedEvent().Command.Lookup("insert").StringValue(); got != "predictors_2" {
+            t.Errorf("inserted into %s, want predictors_2", got)
+        }
+
+        predictor, err := client.GetPredictorByID(context.Background(), id.Hex())
+        if err != nil {
+            t.Fatalf("GetPredictorByID: %v", err)
+        }
+        if predictor.Name != "churn" {
+            t.Errorf("GetPredictorByID name = %q, want churn", predictor.Name)
+        }
+        var searched []string
+        for _, evt := range mt.GetAllStartedEvents() {
+            if evt.CommandName == "find" {
+                searched = append(searched, evt.Command.Lookup("find").StringValue())
+            }
+        }
+        if want := []string{"predictors_0", "predictors_1", "predictors_2"}; !reflect.DeepEqual(searched, want) {
+            t.Errorf("searched %v, want %v", searched, want)
+        }
+    })
+}
+
+func TestDecodePredictorFieldMap(t *testing.T) {
+    client := &MindsDBClient{fields: FieldMap{Name: "model_name"}}
+    id := primitive.NewObjectID()
+    raw := func(doc bson.D) bson.Raw {
+        b, err := bson.Marshal(doc)
+        if err != nil {
+            t.Fatalf("bson.Marshal: %v", err)
+        }
+        return b
+    }
+
+    predictor, err := client.decodePredictor(raw(bson.D{{Key: "_id", Value: id}, {Key: "name", Value: "ignored"}, {Key: "model_name", Value: "churn"}}))
+    if err != nil {
+        t.Fatalf("decodePredictor: %v", err)
+    }
+    if want := (Predictor{ID: id.Hex(), Name: "churn"}); predictor != want {
+        t.Errorf("decodePredictor = %+v, want %+v", predictor, want)
+    }
+
+    if _, err := client.decodePredictor(raw(bson.D{{Key: "_id", Value: "p1"}, {Key: "model_name", Value: 7}})); err == nil {
+        t.Error("decodePredictor with a non-string name: err = nil")
+    }
+    if _, err := client.decodePredictor(raw(bson.D{{Key: "model_name", Value: "churn"}})); err == nil {
+        t.Error("decodePredictor without _id: err = nil")
+    }
+
+    encoded := client.encodePredictor(Predictor{ID: "p1", Name: "churn"})
+    if want := (bson.D{{Key: "_id", Value: "p1"}, {Key: "model_name", Value: "churn"}}); !reflect.DeepEqual(encoded, want) {
+        t.Errorf("encodePredictor = %v, want %v", encoded, want)
+    }
+}
+
+func TestGetPredictorsCursor(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    ids := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()}
+    doc := func(i int) bson.D {
+        return bson.D{{Key: "_id", Value: ids[i]}, {Key: "name", Value: fmt.Sprintf("p%d", i)}}
+    }
+
+    mt.Run("pages forward", func(mt *mtest.T) {
+        client := newMockClient(mt)
+        ns := mt.DB.Name() + "." + mt.Coll.Name()
+        mt.AddMockResponses(
+            mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, doc(0), doc(1)),
+            mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, doc(2)),
+        )
+
+        page, next, err := client.GetPredictorsCursor(context.Background(), "", 2)
+        if err != nil {
+            t.Fatalf("first page: %v", err)
+        }
+        if len(page) != 2 || page[0].ID != ids[0].Hex() || next != ids[1].Hex() {
+            t.Fatalf("first page = %v, next %q, want the first two and next %s", page, next, ids[1].Hex())
+        }
+        filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+        if elems, _ := filter.Elements(); len(elems) != 0 {
+            t.Errorf("first page filter = %v, want empty", filter)
+        }
+
+        page, next, err = client.GetPredictorsCursor(context.Background(), next, 2)
+        if err != nil {
+            t.Fatalf("second page: %v", err)
+        }
+        if len(page) != 1 || page[0].ID != ids[2].Hex() || next != "" {
+            t.Errorf("second page = %v, next %q, want only the third and no next", page, next)
+        }
+        after := mt.GetStartedEvent().Command.Lookup("filter", "_id", "$gt").ObjectID()
+        if after != ids[1] {
+            t.Errorf("second page starts after %s, want %s", after.Hex(), ids[1].Hex())
+        }
+    })
+
+    mt.Run("merges shards", func(mt *mtest.T) {
+        client := newMockClient(mt, "predictors_0", "predictors_1")
+        mt.AddMockResponses(
+            mtest.CreateCursorResponse(0, mt.DB.Name()+".predictors_0", mtest.FirstBatch, doc(1)),
+            mtest.CreateCursorResponse(0, mt.DB.Name()+".predictors_1", mtest.FirstBatch, doc(0), doc(2)),
+        )
+
+        page, next, err := client.GetPredictorsCursor(context.Background(), "", 2)
+        if err != nil {
+            t.Fatalf("GetPredictorsCursor: %v", err)
+        }
+        if len(page) != 2 || page[0].ID != ids[0].Hex() || page[1].ID != ids[1].Hex() || next != ids[1].Hex() {
+            t.Errorf("page = %v, next %q, want the first two IDs in order", page, next)
+        }
+    })
+
+    mt.Run("invalid", func(mt *mtest.T) {
+        client := newMockClient(mt)
+        if _, _, err := client.GetPredictorsCursor(context.Background(), "not-an-id", 2); !errors.Is(err, ErrInvalidPredictorID) {
+            t.Errorf("bad cursor error = %v, want ErrInvalidPredictorID", err)
+        }
+        if _, _, err := client.GetPredictorsCursor(context.Background(), "", 0); err == nil {
+            t.Error("zero limit: err = nil")
+        }
+    })
+}
+
+func TestWithURIAuthSource(t *testing.T) {
+    tests := []struct {
+        uri, authSource, want string
+    }{
+        {"mongodb://localhost/?authSource=team", "", "team"},
+        {"mongodb://localhost/?authSource=team", "admin", "admin"},
+        {"mongodb://a:27017,b:27017/mindsdb?replicaSet=rs0&authSource=team", "", "team"},
+        // The driver picks the URI's database or "admin" for an empty source.
+        {"mongodb://localhost/mindsdb", "", ""},
+    }
+    for _, tt := range tests {
+        auth := withURIAuthSource(options.Credential{Username: "app", AuthSource: tt.authSource}, tt.uri)
+        if auth.AuthSource != tt.want {
+            t.Errorf("%s with %q: AuthSource = %q, want %q", tt.uri, tt.authSource, auth.AuthSource, tt.want)
+        }
+    }
+}
+
+func TestCreatePredictorHandlerReturnsID(t *testing.T) {
+    store, mock := newMockStore(t)
+    mock.ExpectExec("INSERT INTO predictors (name) VALUES (?);").WithArgs("churn").WillReturnResult(sqlmock.NewResult(42, 1))
+
+    rec := httptest.NewRecorder()
+    CreatePredictorHandler(store, rec, httptest.NewRequest("POST", "/predictors", strings.NewReader(`{"name":" churn "}`)))
+    if rec.Code != http.StatusCreated {
+        t.Fatalf("status = %d, want 201", rec.Code)
+    }
+    var got Predictor
+    if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+        t.Fatalf("decoding response: %v", err)
+    }
+    if got != (Predictor{ID: "42", Name: "churn"}) {
+        t.Errorf("response = %+v, want id 42 named churn", got)
+    }
+}
+
+func TestCreatePredictorReturnsObjectID(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    mt.Run("insert", func(mt *mtest.T) {
+        mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+        id, err := newMockClient(mt).CreatePredictor(context.Background(), Predictor{Name: "churn"})
+        if err != nil {
+            t.Fatalf("CreatePredictor: %v", err)
+        }
+        if _, err := primitive.ObjectIDFromHex(id); err != nil {
+            t.Errorf("id = %q, want a hex ObjectID", id)
+        }
+    })
+}
+
+func TestUpsertPredictors(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    mt.Run("mixed names", func(mt *mtest.T) {
+        mt.AddMockResponses(mtest.CreateSuccessResponse(
+            bson.E{Key: "n", Value: 2},
+            bson.E{Key: "nModified", Value: 1},
+            bson.E{Key: "upserted", Value: bson.A{bson.D{{Key: "index", Value: 0}, {Key: "_id", Value: primitive.NewObjectID()}}}},
+        ))
+
+        predictors := []Predictor{{Name: "new"}, {Name: " existing "}, {Name: ""}, {Name: strings.Repeat("a", 256)}}
+        inserted, updated, err := newMockClient(mt).UpsertPredictors(context.Background(), predictors)
+        if inserted != 1 || updated != 1 {
+            t.Errorf("UpsertPredictors = %d inserted, %d updated, want 1, 1", inserted, updated)
+        }
+        var batchErr *BatchError
+        if !errors.As(err, &batchErr) || len(batchErr.Failed) != 2 {
+            t.Fatalf("error = %v, want a BatchError for items 2 and 3", err)
+        }
+        var validationErr *ValidationError
+        for _, i := range []int{2, 3} {
+            if !errors.As(batchErr.Failed[i], &validationErr) {
+                t.Errorf("item %d error = %v, want a ValidationError", i, batchErr.Failed[i])
+            }
+        }
+
+        // Only the valid predictors are written, with trimmed names.
+        updates, _ := mt.GetStartedEvent().Command.Lookup("updates").Array().Values()
+        var names []string
+        for _, update := range updates {
+            names = append(names, update.Document().Lookup("q", "name").StringValue())
+        }
+        if want := []string{"new", "existing"}; !reflect.DeepEqual(names, want) {
+            t.Errorf("upserted names = %v, want %v", names, want)
+        }
+    })
+}
+
+func TestUpsertPredictorsOrdered(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+    mt.Run("stops at a failed write", func(mt *mtest.T) {
+        mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 1, Code: 2, Message: "bad update"}))
+
+        predictors := []Predictor{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+        _, _, err := newMockClient(mt).UpsertPredictors(context.Background(), predictors, OrderedUpsert())
+        var batchErr *BatchError
+        if !errors.As(err, &batchErr) || batchErr.Failed[1] == nil || len(batchErr.Failed) != 1 {
+            t.Fatalf("error = %v, want a BatchError for item 1", err)
+        }
+        if want := []int{2}; !reflect.DeepEqual(batchErr.Skipped, want) {
+            t.Errorf("Skipped = %v, want %v", batchErr.Skipped, want)
+        }
+        if ordered, _ := mt.GetStartedEvent().Command.Lookup("ordered").BooleanOK(); !ordered {
+            t.Error("bulk write was not ordered")
+        }
+    })
+
+    mt.Run("stops at an invalid name", func(mt *mtest.T) {
+        mt.AddMockResponses(mtest.CreateSuccessResponse(
+            bson.E{Key: "n", Value: 1},
+            bson.E{Key: "upserted", Value: bson.A{bson.D{{Key: "index", Value: 0}, {Key: "_id", Value: primitive.NewObjectID()}}}},
+        ))
+
+        predictors := []Predictor{{Name: "a"}, {Name: " "}, {Name: "c"}}
+        inserted, _, err := newMockClient(mt).UpsertPredictors(context.Background(), predictors, OrderedUpsert())
+        var batchErr *BatchError
+        if inserted != 1 || !errors.As(err, &batchErr) || batchErr.Failed[1] == nil {
+            t.Fatalf("UpsertPredictors = %d, %v, want 1 inserted and item 1 failed", inserted, err)
+        }
+        if want := []int{2}; !reflect.DeepEqual(batchErr.Skipped, want) {
+            t.Errorf("Skipped = %v, want %v", batchErr.Skipped, want)
+        }
+        updates, _ := mt.GetStartedEvent().Command.Lookup("updates").Array().Values()
+        if len(updates) != 1 {
+            t.Errorf("wrote %d predictors, want only the one before the invalid name", len(updates))
+        }
+    })
+
+    mt.Run("stops before later shards", func(mt *mtest.T) {
+        // churn and fraud hash to predictors_2, sales to predictors_0.
+        mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 1, Code: 2, Message: "bad update"}))
+
+        predictors := []Predictor{{Name: "churn"}, {Name: "fraud"}, {Name: "sales"}}
+        client := newMockClient(mt, "predictors_0", "predictors_1", "predictors_2")
+        _, _, err := client.UpsertPredictors(context.Background(), predictors, OrderedUpsert())
+        var batchErr *BatchError
+        if !errors.As(err, &batchErr) || !reflect.DeepEqual(batchErr.Skipped, []int{2}) {
+            t.Fatalf("error = %v, want item 2 skipped", err)
+        }
+        if events := mt.GetAllStartedEvents(); len(events) != 1 || events[0].Command.Lookup("update").StringValue() != "predictors_2" {
+            t.Errorf("sent %d commands, want only the write to predictors_2", len(events))
+        }
+    })
+}
+
+// namedPredictors returns documents for predictors with the given names.
+func namedPredictors(names ...string) []bson.D {
+    docs := make([]bson.D, len(names))
+    for i, name := range names {
+        docs[i] = bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "name", Value: name}}
+    }
+    return docs
+}
+
+func TestSearchPredictorsPagesInQuery(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+    mt.Run("one shard", func(mt *mtest.T) {
+        mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.predictors", mtest.FirstBatch, namedPredictors("rent_c")...))
+
+        predictors, err := newMockClient(mt).SearchPredictors(context.Background(), "rent", 2, 4)
+        if err != nil || len(predictors) != 1 {
+            t.Fatalf("SearchPredictors = %v, %v, want one predictor", predictors, err)
+        }
+        cmd := mt.GetStartedEvent().Command
+        if limit, skip := cmd.Lookup("limit").Int64(), cmd.Lookup("skip").Int64(); limit != 2 || skip != 4 {
+            t.Errorf("find limit, skip = %d, %d, want 2, 4", limit, skip)
+        }
+    })
+
+    mt.Run("sharded", func(mt *mtest.T) {
+        mt.AddMockResponses(
+            mtest.CreateCursorResponse(0, "test.predictors_0", mtest.FirstBatch, namedPredictors("rent_a", "rent_d")...),
+            mtest.CreateCursorResponse(0, "test.predictors_1", mtest.FirstBatch, namedPredictors("rent_b", "rent_c")...),
+        )
+
+        predictors, err := newMockClient(mt, "predictors_0", "predictors_1").SearchPredictors(context.Background(), "rent", 2, 1)
+        if err != nil {
+            t.Fatalf("SearchPredictors: %v", err)
+        }
+        var names []string
+        for _, p := range predictors {
+            names = append(names, p.Name)
+        }
+        if want := []string{"rent_b", "rent_c"}; !reflect.DeepEqual(names, want) {
+            t.Errorf("names = %v, want %v", names, want)
+        }
+        // Each shard is asked for no more than offset+limit matches.
+        for _, evt := range mt.GetAllStartedEvents() {
+            if limit := evt.Command.Lookup("limit").Int64(); limit != 3 {
+                t.Errorf("find on %s limit = %d, want 3", evt.Command.Lookup("find").StringValue(), limit)
+            }
+        }
+    })
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gorilla/mux"
+    "github.com/prometheus/client_golang/prometheus"
+    "go.mongodb.org/mongo-driver/event"
+)
+
+// Metrics holds the Prometheus collectors for the HTTP API and the MongoDB
+// connection pool.
+type Metrics struct {
+    operations       *prometheus.CounterVec
+    duration         *prometheus.HistogramVec
+    mongoConnections prometheus.Gauge
+}
+
+// NewMetrics creates the collectors and registers them with reg. Pass a fresh
+// prometheus.NewRegistry() to keep them apart from other instances, e.g. in
+// tests, or prometheus.DefaultRegisterer to use the global registry.
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+    m := &Metrics{
+        operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Name: "mindsdb_predictor_operations_total",
+            Help: "Successful predictor requests by operation: create, read, update or delete.",
+        }, []string{"operation"}),
+        duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+            Name:    "mindsdb_http_request_duration_seconds",
+            Help:    "Latency of HTTP requests by method, route and status.",
+            Buckets: prometheus.DefBuckets,
+        }, []string{"method", "route", "status"}),
+        mongoConnections: prometheus.NewGauge(prometheus.GaugeOpts{
+            Name: "mindsdb_mongo_open_connections",
+            Help: "Connections currently open in the MongoDB pool.",
+        }),
+    }
+    for _, collector := range []prometheus.Collector{m.operations, m.duration, m.mongoConnections} {
+        if err := reg.Register(collector); err != nil {
+            return nil, err
+        }
+    }
+    return m, nil
+}
+
+// WithMetrics reports the MongoDB pool's open connections to m.
+func WithMetrics(m *Metrics) ClientOption {
+    return func(cfg *clientConfig) {
+        cfg.metrics = m
+    }
+}
+
+// handlePoolEvent tracks the open connection count from driver pool events.
+func (m *Metrics) handlePoolEvent(evt *event.PoolEvent) {
+    switch evt.Type {
+    case event.ConnectionCreated:
+        m.mongoConnections.Inc()
+    case event.ConnectionClosed:
+        m.mongoConnections.Dec()
+    }
+}
+
+// predictorOperations maps the methods of the /predictors routes to the
+// operation they count as.
+var predictorOperations = map[string]string{
+    http.MethodPost:   "create",
+    http.MethodGet:    "read",
+    http.MethodPut:    "update",
+    http.MethodPatch:  "update",
+    http.MethodDelete: "delete",
+}
+
+// MetricsMiddleware returns middleware that records every request's latency in
+// m, labelled with the route template rather than the path so that IDs do not
+// create new series, and counts successful predictor operations. Add it to the
+// router with Use so that every route, including new ones, is measured.
+func MetricsMiddleware(m *Metrics) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            start := time.Now()
+            rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+            next.ServeHTTP(rec, r)
+
+            route := r.URL.Path
+            if current := mux.CurrentRoute(r); current != nil {
+                if template, err := current.GetPathTemplate(); err == nil {
+                    route = template
+                }
+            }
+            m.duration.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Observe(time.Since(start).Seconds())
+
+            operation, ok := predictorOperations[r.Method]
+            if ok && rec.status < http.StatusBadRequest && strings.HasPrefix(route, "/predictors") {
+                m.operations.WithLabelValues(operation).Inc()
+            }
+        })
+    }
+}
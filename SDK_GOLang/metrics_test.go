@@ -0,0 +1,70 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/gorilla/mux"
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+    registry := prometheus.NewRegistry()
+    metrics, err := NewMetrics(registry)
+    if err != nil {
+        t.Fatalf("NewMetrics: %v", err)
+    }
+
+    r := mux.NewRouter()
+    r.HandleFunc("/predictors", func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusCreated)
+    }).Methods("POST")
+    r.HandleFunc("/predictors/{id}", func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusNotFound)
+    }).Methods("GET")
+    r.Use(MetricsMiddleware(metrics))
+
+    r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/predictors", nil))
+    r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/predictors/66a1b2c3d4e5f60718293a4b", nil))
+
+    if got := testutil.ToFloat64(metrics.operations.WithLabelValues("create")); got != 1 {
+        t.Errorf("create count = %v, want 1", got)
+    }
+    // The 404 read failed, so it is not counted.
+    if got := testutil.ToFloat64(metrics.operations.WithLabelValues("read")); got != 0 {
+        t.Errorf("read count = %v, want 0", got)
+    }
+
+    families, err := registry.Gather()
+    if err != nil {
+        t.Fatalf("Gather: %v", err)
+    }
+    routes := map[string]bool{}
+    for _, family := range families {
+        if family.GetName() != "mindsdb_http_request_duration_seconds" {
+            continue
+        }
+        for _, metric := range family.GetMetric() {
+            for _, label := range metric.GetLabel() {
+                if label.GetName() == "route" {
+                    routes[label.GetValue()] = true
+                }
+            }
+        }
+    }
+    if !routes["/predictors"] || !routes["/predictors/{id}"] {
+        t.Errorf("route labels = %v, want /predictors and /predictors/{id}", routes)
+    }
+}
+
+func TestNewMetricsRejectsDuplicateRegistration(t *testing.T) {
+    registry := prometheus.NewRegistry()
+    if _, err := NewMetrics(registry); err != nil {
+        t.Fatalf("NewMetrics: %v", err)
+    }
+    if _, err := NewMetrics(registry); err == nil {
+        t.Error("second NewMetrics on the same registry succeeded, want error")
+    }
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+    "errors"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// CORSConfig controls which cross-origin requests the HTTP API accepts.
+// The zero value allows same-origin requests only.
+type CORSConfig struct {
+    // AllowedOrigins lists origins such as "https://app.example.com". "*" allows any origin.
+    AllowedOrigins []string
+    // AllowedMethods defaults to the methods the API's routes use when empty.
+    AllowedMethods []string
+    // AllowedHeaders lists request headers a cross-origin request may send.
+    AllowedHeaders []string
+    // AllowCredentials lets browsers send cookies and authorization headers.
+    // It cannot be combined with the "*" origin.
+    AllowCredentials bool
+    // MaxAge is how long browsers may cache a preflight response. Zero omits the header.
+    MaxAge time.Duration
+}
+
+var defaultCORSMethods = []string{
+    http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// Validate reports settings that would open the API up more than intended.
+// Credentials with the "*" origin would let every site make authenticated
+// requests on a user's behalf, which is why browsers refuse the combination.
+func (cfg CORSConfig) Validate() error {
+    if cfg.AllowCredentials && containsFold(cfg.AllowedOrigins, "*") {
+        return errors.New("invalid CORS configuration: AllowCredentials cannot be used with the \"*\" origin, list the allowed origins instead")
+    }
+    return nil
+}
+
+// CORSMiddleware returns middleware that applies cfg to every request. Requests
+// from origins that are neither the server's own nor allowed by cfg are
+// rejected with 403, and preflight OPTIONS requests are answered directly.
+// It should wrap the whole router, since mux only runs its own middleware on
+// matched routes and preflight requests match none. It panics if cfg fails
+// Validate, as a misconfigured server should not start.
+func CORSMiddleware(cfg CORSConfig) func(http.Handler) http.Handler {
+    if err := cfg.Validate(); err != nil {
+        panic(err)
+    }
+    methods := cfg.AllowedMethods
+    if len(methods) == 0 {
+        methods = defaultCORSMethods
+    }
+
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            origin := r.Header.Get("Origin")
+            if origin == "" || isSameOrigin(origin, r) {
+                next.ServeHTTP(w, r)
+                return
+            }
+
+            w.Header().Add("Vary", "Origin")
+            if !cfg.allowsOrigin(origin) {
+                writeJSONError(w, http.StatusForbidden, "Origin not allowed")
+                return
+            }
+
+            if containsFold(cfg.AllowedOrigins, "*") {
+                w.Header().Set("Access-Control-Allow-Origin", "*")
+            } else {
+                w.Header().Set("Access-Control-Allow-Origin", origin)
+            }
+            if cfg.AllowCredentials {
+                w.Header().Set("Access-Control-Allow-Credentials", "true")
+            }
+
+            requestMethod := r.Header.Get("Access-Control-Request-Method")
+            if r.Method != http.MethodOptions || requestMethod == "" {
+                next.ServeHTTP(w, r)
+                return
+            }
+
+            w.Header().Add("Vary", "Access-Control-Request-Method")
+            w.Header().Add("Vary", "Access-Control-Request-Headers")
+            if !containsFold(methods, requestMethod) {
+                writeJSONError(w, http.StatusForbidden, "Method not allowed")
+                return
+            }
+            for _, header := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+                header = strings.TrimSpace(header)
+                if header != "" && !containsFold(cfg.AllowedHeaders, header) {
+                    writeJSONError(w, http.StatusForbidden, "Header not allowed")
+                    return
+                }
+            }
+
+            w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+            if len(cfg.AllowedHeaders) > 0 {
+                w.Header().Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+            }
+            if cfg.MaxAge > 0 {
+                w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+            }
+            w.WriteHeader(http.StatusNoContent)
+        })
+    }
+}
+
+// allowsOrigin reports whether origin is in the allowed list.
+func (cfg CORSConfig) allowsOrigin(origin string) bool {
+    for _, allowed := range cfg.AllowedOrigins {
+        if allowed == "*" || strings.EqualFold(allowed, origin) {
+            return true
+        }
+    }
+    return false
+}
+
+// isSameOrigin reports whether origin has the scheme and host the request was
+// sent to. Behind a proxy that terminates TLS the request arrives over plain
+// HTTP, so an https origin is not the same; list it in AllowedOrigins.
+func isSameOrigin(origin string, r *http.Request) bool {
+    u, err := url.Parse(origin)
+    if err != nil {
+        return false
+    }
+    scheme := "http"
+    if r.TLS != nil {
+        scheme = "https"
+    }
+    return strings.EqualFold(u.Scheme, scheme) && strings.EqualFold(u.Host, r.Host)
+}
+
+// containsFold reports whether list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+    for _, item := range list {
+        if strings.EqualFold(item, s) {
+            return true
+        }
+    }
+    return false
+}
+
+// timeoutBody is the response TimeoutMiddleware sends when a request runs past
+// its deadline, matching the shape of writeJSONError.
+const timeoutBody = `{"error":"Request timed out","status":503}`
+
+// TimeoutMiddleware returns middleware that gives each request at most d to
+// complete. The request context carries the deadline, so store calls made
+// with r.Context() are cancelled when it passes, and the client receives a
+// JSON 503 instead of whatever the handler writes afterwards. A d of zero or
+// less disables the timeout. Responses that finish in time keep the handler's
+// own headers.
+//
+// Wrap it inside RequestLoggingMiddleware so timed-out requests are logged
+// with their 503 status and request ID.
+func TimeoutMiddleware(d time.Duration) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        if d <= 0 {
+            return next
+        }
+        timeout := http.TimeoutHandler(next, d, timeoutBody)
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            tw := &timeoutWriter{ResponseWriter: w}
+            timeout.ServeHTTP(tw, r)
+            tw.flush()
+        })
+    }
+}
+
+// WithTimeout is TimeoutMiddleware: it ends requests still running after d
+// with a JSON 503.
+func WithTimeout(d time.Duration) func(http.Handler) http.Handler {
+    return TimeoutMiddleware(d)
+}
+
+// timeoutWriter labels the body http.TimeoutHandler writes on a timeout as
+// JSON. It holds back a 503 until the body arrives, and sets the content type
+// only if the body is timeoutBody and the handler chose no content type, so
+// the handler's own responses pass through unchanged.
+type timeoutWriter struct {
+    http.ResponseWriter
+    pending int
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+    if status == http.StatusServiceUnavailable {
+        tw.pending = status
+        return
+    }
+    tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+    if tw.pending != 0 && string(b) == timeoutBody && tw.Header().Get("Content-Type") == "" {
+        tw.Header().Set("Content-Type", "application/json")
+    }
+    tw.flush()
+    return tw.ResponseWriter.Write(b)
+}
+
+// flush writes a held-back status, for a 503 sent without a body.
+func (tw *timeoutWriter) flush() {
+    if tw.pending != 0 {
+        tw.ResponseWriter.WriteHeader(tw.pending)
+        tw.pending = 0
+    }
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+    "crypto/tls"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+    cfg := CORSConfig{
+        AllowedOrigins: []string{"https://app.example.com"},
+        AllowedMethods: []string{"GET", "POST", "PATCH"},
+        AllowedHeaders: []string{"Content-Type"},
+        MaxAge:         10 * time.Minute,
+    }
+    handler := CORSMiddleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusTeapot)
+    }))
+
+    tests := []struct {
+        name       string
+        method     string
+        origin     string
+        tls        bool
+        headers    map[string]string
+        wantStatus int
+        wantHeader map[string]string
+    }{
+        {
+            name: "no origin", method: "GET",
+            wantStatus: http.StatusTeapot,
+        },
+        {
+            name: "same origin", method: "GET", origin: "http://api.example.com",
+            wantStatus: http.StatusTeapot,
+            wantHeader: map[string]string{"Access-Control-Allow-Origin": ""},
+        },
+        {
+            name: "same host over TLS", method: "GET", origin: "https://api.example.com", tls: true,
+            wantStatus: http.StatusTeapot,
+        },
+        {
+            // Same host but a different scheme is another origin.
+            name: "scheme mismatch", method: "GET", origin: "https://api.example.com",
+            wantStatus: http.StatusForbidden,
+        },
+        {
+            name: "disallowed origin", method: "GET", origin: "https://evil.example.com",
+            wantStatus: http.StatusForbidden,
+            wantHeader: map[string]string{"Access-Control-Allow-Origin": ""},
+        },
+        {
+            name: "allowed origin", method: "GET", origin: "https://app.example.com",
+            wantStatus: http.StatusTeapot,
+            wantHeader: map[string]string{"Access-Control-Allow-Origin": "https://app.example.com"},
+        },
+        {
+            name: "preflight", method: "OPTIONS", origin: "https://app.example.com",
+            headers:    map[string]string{"Access-Control-Request-Method": "PATCH", "Access-Control-Request-Headers": "content-type"},
+            wantStatus: http.StatusNoContent,
+            wantHeader: map[string]string{
+                "Access-Control-Allow-Origin":  "https://app.example.com",
+                "Access-Control-Allow-Methods": "GET, POST, PATCH",
+                "Access-Control-Allow-Headers": "Content-Type",
+                "Access-Control-Max-Age":       "600",
+            },
+        },
+        {
+            name: "preflight disallowed method", method: "OPTIONS", origin: "https://app.example.com",
+            headers:    map[string]string{"Access-Control-Request-Method": "DELETE"},
+            wantStatus: http.StatusForbidden,
+        },
+        {
+            name: "preflight disallowed header", method: "OPTIONS", origin: "https://app.example.com",
+            headers:    map[string]string{"Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "X-Secret"},
+            wantStatus: http.StatusForbidden,
+        },
+        {
+            name: "preflight from disallowed origin", method: "OPTIONS", origin: "https://evil.example.com",
+            headers:    map[string]string{"Access-Control-Request-Method": "GET"},
+            wantStatus: http.StatusForbidden,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            r := httptest.NewRequest(tt.method, "http://api.example.com/predictors", nil)
+            if tt.tls {
+                r.TLS = &tls.ConnectionState{}
+            }
+            if tt.origin != "" {
+                r.Header.Set("Origin", tt.origin)
+            }
+            for k, v := range tt.headers {
+                r.Header.Set(k, v)
+            }
+
+            rec := httptest.NewRecorder()
+            handler.ServeHTTP(rec, r)
+            if rec.Code != tt.wantStatus {
+                t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+            }
+            for k, want := range tt.wantHeader {
+                if got := rec.Header().Get(k); got != want {
+                    t.Errorf("%s = %q, want %q", k, got, want)
+                }
+            }
+        })
+    }
+}
+
+func TestCORSMiddlewareWildcard(t *testing.T) {
+    handler := CORSMiddleware(CORSConfig{AllowedOrigins: []string{"*"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+    r := httptest.NewRequest("GET", "http://api.example.com/predictors", nil)
+    r.Header.Set("Origin", "https://anywhere.example.com")
+    rec := httptest.NewRecorder()
+    handler.ServeHTTP(rec, r)
+    if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+        t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+    }
+}
+
+func TestCORSMiddlewareWildcardCredentials(t *testing.T) {
+    cfg := CORSConfig{AllowedOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true}
+    if err := cfg.Validate(); err == nil {
+        t.Error("Validate with * and credentials: err = nil")
+    }
+
+    defer func() {
+        if recover() == nil {
+            t.Error("CORSMiddleware with * and credentials did not panic")
+        }
+    }()
+    CORSMiddleware(cfg)
+}
+
+func TestCORSMiddlewareDefaultMethods(t *testing.T) {
+    handler := CORSMiddleware(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+    // Every method the API's routes register passes preflight.
+    for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+        r := httptest.NewRequest("OPTIONS", "http://api.example.com/predictors/1", nil)
+        r.Header.Set("Origin", "https://app.example.com")
+        r.Header.Set("Access-Control-Request-Method", method)
+        rec := httptest.NewRecorder()
+        handler.ServeHTTP(rec, r)
+        if rec.Code != http.StatusNoContent {
+            t.Errorf("preflight for %s = %d, want 204", method, rec.Code)
+        }
+    }
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+    tests := []struct {
+        name            string
+        handler         http.HandlerFunc
+        wantStatus      int
+        wantContentType string
+    }{
+        {"timed out", func(w http.ResponseWriter, r *http.Request) {
+            <-r.Context().Done()
+        }, http.StatusServiceUnavailable, "application/json"},
+        {"no content", func(w http.ResponseWriter, r *http.Request) {
+            w.WriteHeader(http.StatusNoContent)
+        }, http.StatusNoContent, ""},
+        {"plain text", func(w http.ResponseWriter, r *http.Request) {
+            w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+            w.Write([]byte("metrics"))
+        }, http.StatusOK, "text/plain; version=0.0.4"},
+        {"own 503", func(w http.ResponseWriter, r *http.Request) {
+            w.Header().Set("Content-Type", "text/html")
+            w.WriteHeader(http.StatusServiceUnavailable)
+        }, http.StatusServiceUnavailable, "text/html"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rec := httptest.NewRecorder()
+            WithTimeout(50*time.Millisecond)(tt.handler).ServeHTTP(rec, httptest.NewRequest("GET", "/predictors", nil))
+            if rec.Code != tt.wantStatus {
+                t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+            }
+            if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+                t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+            }
+            if tt.wantStatus == http.StatusServiceUnavailable && tt.wantContentType == "application/json" && rec.Body.String() != timeoutBody {
+                t.Errorf("body = %q, want %q", rec.Body.String(), timeoutBody)
+            }
+        })
+    }
+}
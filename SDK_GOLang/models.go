@@ -0,0 +1,531 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "math"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// ErrModelNotReady is returned by Predict when the model has not finished training.
+var ErrModelNotReady = errors.New("model is not ready")
+
+// ErrLowConfidence is returned by Predict when the model's confidence is below
+// the threshold set with MinConfidence.
+var ErrLowConfidence = errors.New("prediction confidence below threshold")
+
+// confidenceSuffix ends the name of the column in which MindsDB reports its
+// confidence in each predicted target, e.g. "price_confidence".
+const confidenceSuffix = "_confidence"
+
+// modelReadyPollInterval is how often Predict checks a training model's status
+// while waiting for it to become ready.
+const modelReadyPollInterval = 2 * time.Second
+
+// notReadyStatuses are the model statuses MindsDB reports before training has
+// completed, in which it refuses to predict.
+var notReadyStatuses = map[string]bool{"generating": true, "training": true}
+
+// modelNotReady reports whether model is still training, which explains a
+// failed prediction query. MindsDB's error text for this varies between
+// versions, so the model's status is checked instead of the message.
+func (store *MySQLStore) modelNotReady(ctx context.Context, model string) bool {
+    status, err := store.GetModelStatus(ctx, model)
+    return err == nil && notReadyStatuses[status]
+}
+
+// PredictOption changes how a single Predict call runs.
+type PredictOption func(*predictConfig)
+
+type predictConfig struct {
+    dryRun        bool
+    correlationID string
+    minConfidence float64
+}
+
+// DryRun makes Predict build the prediction query without sending it. Predict
+// then returns a *DryRunResult error holding the query and its arguments.
+func DryRun() PredictOption {
+    return func(cfg *predictConfig) {
+        cfg.dryRun = true
+    }
+}
+
+// CorrelationID tags the prediction query with id, overriding any ID set on
+// the context with WithCorrelationID.
+func CorrelationID(id string) PredictOption {
+    return func(cfg *predictConfig) {
+        cfg.correlationID = id
+    }
+}
+
+// MinConfidence makes Predict abstain when the model is less than threshold
+// confident, e.g. 0.8. Predict then returns the result together with an error
+// wrapping ErrLowConfidence, so that callers can send the case for review.
+// A threshold of zero or less, the default, disables the check.
+func MinConfidence(threshold float64) PredictOption {
+    return func(cfg *predictConfig) {
+        cfg.minConfidence = threshold
+    }
+}
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a context whose prediction queries are tagged with
+// id. Without one, Predict uses the request ID set by RequestLoggingMiddleware.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+    return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// maxCorrelationIDLength caps the correlation ID written into a query.
+const maxCorrelationIDLength = 64
+
+// correlationComment returns a SQL comment carrying id so that the query can
+// be matched up in MindsDB's logs, or "" if id is empty. Characters outside
+// letters, digits and "-_.:" are dropped so the ID cannot close the comment.
+func correlationComment(id string) string {
+    var b strings.Builder
+    for _, r := range id {
+        if b.Len() == maxCorrelationIDLength {
+            break
+        }
+        switch {
+        case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+            r == '-', r == '_', r == '.', r == ':':
+            b.WriteRune(r)
+        }
+    }
+    if b.Len() == 0 {
+        return ""
+    }
+    return "/* corr_id: " + b.String() + " */ "
+}
+
+// DryRunResult is the error Predict returns for a DryRun call. Retrieve it
+// with errors.As to inspect the query that would have been run.
+type DryRunResult struct {
+    Query string
+    Args  []interface{}
+}
+
+func (result *DryRunResult) Error() string {
+    return "dry run, query not executed: " + result.Query
+}
+
+// ModelSpec describes a MindsDB model to train with CreateModel.
+type ModelSpec struct {
+    // Name of the model, created in the mindsdb project.
+    Name string
+    // Integration is the data source the training data is read from.
+    Integration string
+    // Select is the query run against Integration to fetch the training data.
+    Select string
+    // Target is the column the model learns to predict.
+    Target string
+    // AutoSuffix retries under Name_v2, Name_v3 and so on when a model called
+    // Name already exists, instead of failing.
+    AutoSuffix bool
+}
+
+// maxModelVersion bounds the suffixes CreateModel tries with AutoSuffix.
+const maxModelVersion = 100
+
+// modelExists reports whether a model called name is listed in MindsDB. It is
+// used to explain a failed CREATE MODEL, since the error text for a taken name
+// varies between MindsDB versions.
+func (store *MySQLStore) modelExists(ctx context.Context, name string) bool {
+    _, err := store.GetModelStatus(ctx, name)
+    return err == nil
+}
+
+// validate reports fields CreateModel cannot do without.
+func (spec ModelSpec) validate() error {
+    switch {
+    case spec.Name == "":
+        return errors.New("model spec: name is required")
+    case spec.Integration == "":
+        return errors.New("model spec: integration is required")
+    case strings.TrimSpace(spec.Select) == "":
+        return errors.New("model spec: select query is required")
+    case spec.Target == "":
+        return errors.New("model spec: target column is required")
+    }
+    return nil
+}
+
+// quoteIdent quotes a MindsDB identifier with backticks.
+func quoteIdent(name string) string {
+    return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// CreateModel issues a CREATE MODEL statement that trains spec.Target from the
+// rows spec.Select returns, and returns the name the model was created under.
+// That is spec.Name unless spec.AutoSuffix picked a free versioned name.
+// MindsDB trains asynchronously, so the model is usually still training when
+// CreateModel returns.
+func (store *MySQLStore) CreateModel(ctx context.Context, spec ModelSpec) (string, error) {
+    if err := spec.validate(); err != nil {
+        return "", err
+    }
+
+    selectSQL := strings.TrimSuffix(strings.TrimSpace(spec.Select), ";")
+    name := spec.Name
+    for version := 2; ; version++ {
+        query := fmt.Sprintf("CREATE MODEL mindsdb.%s FROM %s (%s) PREDICT %s;",
+            quoteIdent(name), quoteIdent(spec.Integration), selectSQL, quoteIdent(spec.Target))
+
+        _, err := store.db.ExecContext(ctx, query)
+        if err == nil {
+            return name, nil
+        }
+        if !spec.AutoSuffix || version > maxModelVersion || !store.modelExists(ctx, name) {
+            return "", fmt.Errorf("failed to create model %s: %w", name, err)
+        }
+        name = fmt.Sprintf("%s_v%d", spec.Name, version)
+    }
+}
+
+// Predict queries a trained model with input as the feature values and returns
+// the single result row keyed by column name. Numeric values are returned as
+// float64, NULLs as nil and everything else as string. If the model is still
+// training it returns an error wrapping ErrModelNotReady, after waiting for the
+// model first when the store was created with WithModelReadyWait. The query
+// carries the correlation ID from CorrelationID or the context as a comment.
+// With MinConfidence, a result whose confidence is below the threshold is
+// returned with an error wrapping ErrLowConfidence.
+func (store *MySQLStore) Predict(ctx context.Context, model string, input map[string]interface{}, opts ...PredictOption) (map[string]interface{}, error) {
+    if model == "" {
+        return nil, errors.New("model name is required")
+    }
+
+    var cfg predictConfig
+    for _, opt := range opts {
+        opt(&cfg)
+    }
+    if cfg.correlationID == "" {
+        cfg.correlationID, _ = ctx.Value(correlationIDKey{}).(string)
+    }
+    if cfg.correlationID == "" {
+        cfg.correlationID = RequestIDFromContext(ctx)
+    }
+
+    query, args := buildPredictQuery(model, input)
+    query = correlationComment(cfg.correlationID) + query
+    if cfg.dryRun {
+        return nil, &DryRunResult{Query: query, Args: args}
+    }
+
+    result, err := store.predict(ctx, model, query, args)
+    if errors.Is(err, ErrModelNotReady) && store.readyWait > 0 {
+        if err := store.waitForReady(ctx, model); err != nil {
+            return nil, err
+        }
+        result, err = store.predict(ctx, model, query, args)
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    // Compare confidence before rounding, which could lift it over the threshold.
+    if cfg.minConfidence > 0 {
+        err = checkConfidence(model, result, cfg.minConfidence)
+    }
+    if store.floatPrecision >= 0 {
+        for column, value := range result {
+            if f, ok := value.(float64); ok {
+                result[column] = roundFloat(f, store.floatPrecision)
+            }
+        }
+    }
+    return result, err
+}
+
+// checkConfidence returns an error wrapping ErrLowConfidence if the lowest of
+// the confidence columns in result is below threshold, or an error if result
+// has none to compare.
+func checkConfidence(model string, result map[string]interface{}, threshold float64) error {
+    confidence, found := math.Inf(1), false
+    for column, value := range result {
+        if !strings.HasSuffix(column, confidenceSuffix) {
+            continue
+        }
+        f, ok := value.(float64)
+        if !ok {
+            return fmt.Errorf("model %s returned a non-numeric %s: %v", model, column, value)
+        }
+        confidence, found = math.Min(confidence, f), true
+    }
+    if !found {
+        return fmt.Errorf("model %s returned no %s column to check against the threshold", model, confidenceSuffix)
+    }
+    if confidence < threshold {
+        return fmt.Errorf("model %s: %w (%g < %g)", model, ErrLowConfidence, confidence, threshold)
+    }
+    return nil
+}
+
+// predict runs a single prediction query against model.
+func (store *MySQLStore) predict(ctx context.Context, model, query string, args []interface{}) (map[string]interface{}, error) {
+    rows, err := store.db.QueryContext(ctx, query, args...)
+    if err != nil {
+        if store.modelNotReady(ctx, model) {
+            return nil, fmt.Errorf("failed to predict with model %s: %w (%v)", model, ErrModelNotReady, err)
+        }
+        return nil, fmt.Errorf("failed to predict with model %s: %w", model, err)
+    }
+    defer rows.Close()
+
+    if !rows.Next() {
+        if err := rows.Err(); err != nil {
+            return nil, fmt.Errorf("failed to predict with model %s: %w", model, err)
+        }
+        return nil, fmt.Errorf("model %s returned no prediction", model)
+    }
+    return scanRow(rows)
+}
+
+// waitForReady waits for model to finish training for up to the store's ready
+// wait, returning an error wrapping ErrModelNotReady if it doesn't.
+func (store *MySQLStore) waitForReady(ctx context.Context, model string) error {
+    waitCtx, cancel := context.WithTimeout(ctx, store.readyWait)
+    defer cancel()
+
+    err := store.WaitForModel(waitCtx, model, min(modelReadyPollInterval, store.readyWait))
+    if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+        return fmt.Errorf("model %s: %w after waiting %s", model, ErrModelNotReady, store.readyWait)
+    }
+    return err
+}
+
+// DropAllModels drops every model in project and returns how many were
+// dropped. It refuses to run unless confirm is true. A model that fails to drop
+// doesn't stop the rest; the failures are returned together.
+func (store *MySQLStore) DropAllModels(ctx context.Context, project string, confirm bool) (int64, error) {
+    if !confirm {
+        return 0, fmt.Errorf("refusing to drop all models in %s without confirmation", project)
+    }
+    if project == "" {
+        return 0, errors.New("project name is required")
+    }
+
+    rows, err := store.db.QueryContext(ctx, fmt.Sprintf("SELECT name FROM %s.models;", quoteIdent(project)))
+    if err != nil {
+        return 0, fmt.Errorf("failed to list models in %s: %w", project, err)
+    }
+    var names []string
+    for rows.Next() {
+        var name string
+        if err := rows.Scan(&name); err != nil {
+            rows.Close()
+            return 0, fmt.Errorf("error scanning row: %w", err)
+        }
+        names = append(names, name)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return 0, fmt.Errorf("failed to list models in %s: %w", project, err)
+    }
+
+    var dropped int64
+    var errs []error
+    for _, name := range names {
+        query := fmt.Sprintf("DROP MODEL %s.%s;", quoteIdent(project), quoteIdent(name))
+        if _, err := store.db.ExecContext(ctx, query); err != nil {
+            if ctx.Err() != nil {
+                return dropped, ctx.Err()
+            }
+            errs = append(errs, fmt.Errorf("failed to drop model %s: %w", name, err))
+            continue
+        }
+        dropped++
+    }
+    return dropped, errors.Join(errs...)
+}
+
+// GetModelStatus returns the training status MindsDB reports for the model,
+// such as "generating", "training", "complete" or "error".
+func (store *MySQLStore) GetModelStatus(ctx context.Context, name string) (string, error) {
+    var status string
+    err := store.db.QueryRowContext(ctx, "SELECT status FROM mindsdb.models WHERE name = ?;", name).Scan(&status)
+    if errors.Is(err, sql.ErrNoRows) {
+        return "", fmt.Errorf("model %s not found", name)
+    }
+    if err != nil {
+        return "", fmt.Errorf("failed to get status of model %s: %w", name, err)
+    }
+    return status, nil
+}
+
+// WaitForModel checks the status of the model every poll until training is
+// complete. It returns an error as soon as the status is "error", and
+// ctx.Err() if ctx is done first.
+func (store *MySQLStore) WaitForModel(ctx context.Context, name string, poll time.Duration) error {
+    if poll <= 0 {
+        return fmt.Errorf("poll interval must be positive, got %s", poll)
+    }
+
+    ticker := time.NewTicker(poll)
+    defer ticker.Stop()
+
+    for {
+        status, err := store.GetModelStatus(ctx, name)
+        if err != nil {
+            if ctx.Err() != nil {
+                return ctx.Err()
+            }
+            return err
+        }
+        switch status {
+        case "complete":
+            return nil
+        case "error":
+            return fmt.Errorf("model %s failed to train", name)
+        }
+
+        select {
+        case <-ctx.Done():
+            return ctx.Err()
+        case <-ticker.C:
+        }
+    }
+}
+
+// ModelStatusEvent reports a change in a model's training status.
+type ModelStatusEvent struct {
+    Name string
+    // Previous is the status before the change, empty for the first report.
+    Previous string
+    Status   string
+}
+
+// MonitorModels polls the status of the named models every interval from a
+// single goroutine and sends an event each time a status changes, starting
+// with each model's current status. Failed polls are logged and retried on
+// the next tick. The channel is closed when ctx is done.
+func (store *MySQLStore) MonitorModels(ctx context.Context, names []string, interval time.Duration) (<-chan ModelStatusEvent, error) {
+    if len(names) == 0 {
+        return nil, errors.New("at least one model name is required")
+    }
+    if interval <= 0 {
+        return nil, fmt.Errorf("poll interval must be positive, got %s", interval)
+    }
+
+    events := make(chan ModelStatusEvent, len(names))
+    go func() {
+        defer close(events)
+
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+
+        statuses := make(map[string]string, len(names))
+        for {
+            for _, name := range names {
+                status, err := store.GetModelStatus(ctx, name)
+                if err != nil {
+                    if ctx.Err() != nil {
+                        return
+                    }
+                    logger.Error("Failed to poll model status", "model", name, "error", err)
+                    continue
+                }
+                if status == statuses[name] {
+                    continue
+                }
+
+                event := ModelStatusEvent{Name: name, Previous: statuses[name], Status: status}
+                statuses[name] = status
+                select {
+                case events <- event:
+                case <-ctx.Done():
+                    return
+                }
+            }
+
+            select {
+            case <-ctx.Done():
+                return
+            case <-ticker.C:
+            }
+        }
+    }()
+    return events, nil
+}
+
+// buildPredictQuery builds the prediction SELECT for model, binding each input
+// value as a parameter. Columns are sorted so the query text is stable.
+func buildPredictQuery(model string, input map[string]interface{}) (string, []interface{}) {
+    columns := make([]string, 0, len(input))
+    for column := range input {
+        columns = append(columns, column)
+    }
+    sort.Strings(columns)
+
+    var b strings.Builder
+    b.WriteString("SELECT * FROM mindsdb.")
+    b.WriteString(quoteIdent(model))
+    args := make([]interface{}, 0, len(columns))
+    for i, column := range columns {
+        if i == 0 {
+            b.WriteString(" WHERE ")
+        } else {
+            b.WriteString(" AND ")
+        }
+        b.WriteString(quoteIdent(column))
+        b.WriteString(" = ?")
+        args = append(args, input[column])
+    }
+    b.WriteString(";")
+
+    return b.String(), args
+}
+
+// scanRow scans the current row into a map keyed by column name. Columns are
+// read as raw bytes so that any column type can be handled.
+func scanRow(rows *sql.Rows) (map[string]interface{}, error) {
+    columns, err := rows.Columns()
+    if err != nil {
+        return nil, fmt.Errorf("error reading columns: %w", err)
+    }
+
+    values := make([]sql.RawBytes, len(columns))
+    dest := make([]interface{}, len(columns))
+    for i := range values {
+        dest[i] = &values[i]
+    }
+    if err := rows.Scan(dest...); err != nil {
+        return nil, fmt.Errorf("error scanning row: %w", err)
+    }
+
+    result := make(map[string]interface{}, len(columns))
+    for i, column := range columns {
+        result[column] = coerceValue(values[i])
+    }
+    return result, nil
+}
+
+// coerceValue converts a raw column value into nil, a float64 or a string.
+func coerceValue(raw sql.RawBytes) interface{} {
+    if raw == nil {
+        return nil
+    }
+    text := string(raw)
+    if f, err := strconv.ParseFloat(text, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+        return f
+    }
+    return text
+}
+
+// roundFloat rounds f to the given number of decimal places.
+func roundFloat(f float64, decimals int) float64 {
+    scale := math.Pow(10, float64(decimals))
+    rounded := math.Round(f*scale) / scale
+    if math.IsInf(rounded, 0) || math.IsNaN(rounded) {
+        return f
+    }
+    return rounded
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "math"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/DATA-DOG/go-sqlmock"
+)
+
+// newMockStore returns a MySQLStore backed by sqlmock. Queries are matched
+// exactly, and all expectations must be met by the end of the test.
+func newMockStore(t *testing.T) (*MySQLStore, sqlmock.Sqlmock) {
+    t.Helper()
+    db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+    if err != nil {
+        t.Fatalf("sqlmock.New: %v", err)
+    }
+    t.Cleanup(func() {
+        if err := mock.ExpectationsWereMet(); err != nil {
+            t.Error(err)
+        }
+        db.Close()
+    })
+    return &MySQLStore{db: db, floatPrecision: -1}, mock
+}
+
+const rentalsQuery = "SELECT * FROM mindsdb.`home_rentals_model` WHERE `sqft` = ?;"
+
+func TestPredictMinConfidence(t *testing.T) {
+    tests := []struct {
+        name       string
+        confidence float64
+        precision  int
+        wantErr    error
+    }{
+        {"above threshold", 0.91, -1, nil},
+        {"below threshold", 0.76, -1, ErrLowConfidence},
+        // Rounding to one decimal would make 0.76 pass as 0.8.
+        {"below threshold before rounding", 0.76, 1, ErrLowConfidence},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            store, mock := newMockStore(t)
+            store.floatPrecision = tt.precision
+            mock.ExpectQuery(rentalsQuery).WithArgs(900).WillReturnRows(
+                sqlmock.NewRows([]string{"rental_price", "rental_price_confidence"}).AddRow("3901.5", tt.confidence))
+
+            result, err := store.Predict(context.Background(), "home_rentals_model", map[string]interface{}{"sqft": 900}, MinConfidence(0.8))
+            if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+                t.Fatalf("Predict error = %v, want %v", err, tt.wantErr)
+            }
+            if result == nil {
+                t.Fatal("Predict returned no result, want the row alongside the error")
+            }
+        })
+    }
+}
+
+func TestPredictMinConfidenceWithoutConfidenceColumn(t *testing.T) {
+    store, mock := newMockStore(t)
+    mock.ExpectQuery(rentalsQuery).WithArgs(900).WillReturnRows(
+        sqlmock.NewRows([]string{"rental_price"}).AddRow("3901.5"))
+
+    _, err := store.Predict(context.Background(), "home_rentals_model", map[string]interface{}{"sqft": 900}, MinConfidence(0.8))
+    if err == nil || errors.Is(err, ErrLowConfidence) {
+        t.Fatalf("Predict error = %v, want a missing confidence error", err)
+    }
+}
+
+func TestCreateModelAutoSuffix(t *testing.T) {
+    spec := ModelSpec{
+        Name:        "home_rentals_model",
+        Integration: "example_db",
+        Select:      "SELECT * FROM demo_data.home_rentals;",
+        Target:      "rental_price",
+        AutoSuffix:  true,
+    }
+    createQuery := func(name string) string {
+        return "CREATE MODEL mindsdb.`" + name + "` FROM `example_db` (SELECT * FROM demo_data.home_rentals) PREDICT `rental_price`;"
+    }
+    // The wording differs between MindsDB versions, so it is never matched.
+    createErr := errors.New("Error 1149: could not create model")
+
+    t.Run("name taken", func(t *testing.T) {
+        store, mock := newMockStore(t)
+        mock.ExpectExec(createQuery("home_rentals_model")).WillReturnError(createErr)
+        mock.ExpectQuery(statusQuery).WithArgs("home_rentals_model").WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("complete"))
+        mock.ExpectExec(createQuery("home_rentals_model_v2")).WillReturnError(createErr)
+        mock.ExpectQuery(statusQuery).WithArgs("home_rentals_model_v2").WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("training"))
+        mock.ExpectExec(createQuery("home_rentals_model_v3")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+        name, err := store.CreateModel(context.Background(), spec)
+        if err != nil {
+            t.Fatalf("CreateModel: %v", err)
+        }
+        if name != "home_rentals_model_v3" {
+            t.Errorf("CreateModel = %q, want home_rentals_model_v3", name)
+        }
+    })
+
+    t.Run("other error", func(t *testing.T) {
+        store, mock := newMockStore(t)
+        denied := errors.New("Error 1045: access denied, model already exists in cache")
+        mock.ExpectExec(createQuery("home_rentals_model")).WillReturnError(denied)
+        mock.ExpectQuery(statusQuery).WithArgs("home_rentals_model").WillReturnRows(sqlmock.NewRows([]string{"status"}))
+
+        if _, err := store.CreateModel(context.Background(), spec); !errors.Is(err, denied) {
+            t.Errorf("CreateModel error = %v, want the access error", err)
+        }
+    })
+
+    t.Run("without AutoSuffix", func(t *testing.T) {
+        // Only the CREATE is expected; the name isn't looked up.
+        store, mock := newMockStore(t)
+        mock.ExpectExec(createQuery("home_rentals_model")).WillReturnError(createErr)
+
+        strict := spec
+        strict.AutoSuffix = false
+        if _, err := store.CreateModel(context.Background(), strict); !errors.Is(err, createErr) {
+            t.Errorf("CreateModel error = %v, want the create error", err)
+        }
+    })
+}
+
+func TestRoundFloat(t *testing.T) {
+    tests := []struct {
+        f, want float64
+    }{
+        {3901.456, 3901.46},
+        {3901.454, 3901.45},
+        {-2.345678, -2.35},
+        {0.125, 0.13},
+        {42, 42},
+        // Scaling would overflow, so the value is returned unchanged.
+        {math.MaxFloat64, math.MaxFloat64},
+    }
+    for _, tt := range tests {
+        if got := roundFloat(tt.f, 2); got != tt.want {
+            t.Errorf("roundFloat(%v, 2) = %v, want %v", tt.f, got, tt.want)
+        }
+    }
+}
+
+func TestPredictFloatPrecision(t *testing.T) {
+    store, mock := newMockStore(t)
+    store.floatPrecision = 2
+    mock.ExpectQuery(rentalsQuery).WithArgs(900).WillReturnRows(
+        sqlmock.NewRows([]string{"rental_price", "location"}).AddRow("3901.4567", "great"))
+
+    result, err := store.Predict(context.Background(), "home_rentals_model", map[string]interface{}{"sqft": 900})
+    if err != nil {
+        t.Fatalf("Predict: %v", err)
+    }
+    if want := map[string]interface{}{"rental_price": 3901.46, "location": "great"}; !reflect.DeepEqual(result, want) {
+        t.Errorf("Predict = %v, want %v", result, want)
+    }
+}
+
+const statusQuery = "SELECT status FROM mindsdb.models WHERE name = ?;"
+
+func TestPredictModelNotReady(t *testing.T) {
+    tests := []struct {
+        status  string
+        wantErr error
+    }{
+        {"training", ErrModelNotReady},
+        {"generating", ErrModelNotReady},
+        // A trained model's failures are passed through unchanged.
+        {"complete", nil},
+    }
+    for _, tt := range tests {
+        t.Run(tt.status, func(t *testing.T) {
+            store, mock := newMockStore(t)
+            queryErr := errors.New("Error 1149: unexpected response from the predictor")
+            mock.ExpectQuery(rentalsQuery).WithArgs(900).WillReturnError(queryErr)
+            mock.ExpectQuery(statusQuery).WithArgs("home_rentals_model").WillReturnRows(
+                sqlmock.NewRows([]string{"status"}).AddRow(tt.status))
+
+            _, err := store.Predict(context.Background(), "home_rentals_model", map[string]interface{}{"sqft": 900})
+            if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+                t.Errorf("Predict error = %v, want %v", err, tt.wantErr)
+            }
+            if tt.wantErr == nil && (errors.Is(err, ErrModelNotReady) || !errors.Is(err, queryErr)) {
+                t.Errorf("Predict error = %v, want the query error", err)
+            }
+        })
+    }
+}
+
+func TestPredictWaitsForModel(t *testing.T) {
+    store, mock := newMockStore(t)
+    store.readyWait = time.Second
+    mock.ExpectQuery(rentalsQuery).WithArgs(900).WillReturnError(errors.New("model is busy"))
+    mock.ExpectQuery(statusQuery).WithArgs("home_rentals_model").WillReturnRows(
+        sqlmock.NewRows([]string{"status"}).AddRow("training"))
+    mock.ExpectQuery(statusQuery).WithArgs("home_rentals_model").WillReturnRows(
+        sqlmock.NewRows([]string{"status"}).AddRow("complete"))
+    mock.ExpectQuery(rentalsQuery).WithArgs(900).WillReturnRows(
+        sqlmock.NewRows([]string{"rental_price"}).AddRow("3901.5"))
+
+    result, err := store.Predict(context.Background(), "home_rentals_model", map[string]interface{}{"sqft": 900})
+    if err != nil {
+        t.Fatalf("Predict: %v", err)
+    }
+    if result["rental_price"] != 3901.5 {
+        t.Errorf("rental_price = %v, want 3901.5", result["rental_price"])
+    }
+}
+
+func TestPredictWaitTimesOut(t *testing.T) {
+    store, mock := newMockStore(t)
+    store.readyWait = 50 * time.Millisecond
+    mock.ExpectQuery(rentalsQuery).WithArgs(900).WillReturnError(errors.New("model is busy"))
+    mock.ExpectQuery(statusQuery).WithArgs("home_rentals_model").WillReturnRows(
+        sqlmock.NewRows([]string{"status"}).AddRow("training"))
+    // The status poll outlasts the wait, which cancels it.
+    mock.ExpectQuery(statusQuery).WithArgs("home_rentals_model").WillDelayFor(time.Second).WillReturnRows(
+        sqlmock.NewRows([]string{"status"}).AddRow("training"))
+
+    _, err := store.Predict(context.Background(), "home_rentals_model", map[string]interface{}{"sqft": 900})
+    if !errors.Is(err, ErrModelNotReady) {
+        t.Errorf("Predict error = %v, want ErrModelNotReady", err)
+    }
+}
+
+func TestMonitorModels(t *testing.T) {
+    store, mock := newMockStore(t)
+    expectStatus := func(name, status string) {
+        mock.ExpectQuery(statusQuery).WithArgs(name).WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(status))
+    }
+    expectStatus("a", "generating")
+    expectStatus("b", "training")
+    expectStatus("a", "training")
+    expectStatus("b", "training")
+    // A failed poll is skipped and doesn't count as a change.
+    mock.ExpectQuery(statusQuery).WithArgs("a").WillReturnError(errors.New("connection reset"))
+    expectStatus("b", "complete")
+    expectStatus("a", "complete")
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    events, err := store.MonitorModels(ctx, []string{"a", "b"}, 10*time.Millisecond)
+    if err != nil {
+        t.Fatalf("MonitorModels: %v", err)
+    }
+
+    want := []ModelStatusEvent{
+        {Name: "a", Status: "generating"},
+        {Name: "b", Status: "training"},
+        {Name: "a", Previous: "generating", Status: "training"},
+        {Name: "b", Previous: "training", Status: "complete"},
+        {Name: "a", Previous: "training", Status: "complete"},
+    }
+    var got []ModelStatusEvent
+    timeout := time.After(5 * time.Second)
+    for len(got) < len(want) {
+        select {
+        case event := <-events:
+            got = append(got, event)
+        case <-timeout:
+            t.Fatalf("timed out after events %v", got)
+        }
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("events = %v, want %v", got, want)
+    }
+
+    cancel()
+    for range events {
+    }
+}
+
+func TestDropAllModels(t *testing.T) {
+    t.Run("drops every model", func(t *testing.T) {
+        store, mock := newMockStore(t)
+        mock.ExpectQuery("SELECT name FROM `sandbox`.models;").WillReturnRows(
+            sqlmock.NewRows([]string{"name"}).AddRow("a").AddRow("b"))
+        mock.ExpectExec("DROP MODEL `sandbox`.`a`;").WillReturnResult(sqlmock.NewResult(0, 0))
+        mock.ExpectExec("DROP MODEL `sandbox`.`b`;").WillReturnResult(sqlmock.NewResult(0, 0))
+
+        dropped, err := store.DropAllModels(context.Background(), "sandbox", true)
+        if dropped != 2 || err != nil {
+            t.Errorf("DropAllModels = %d, %v, want 2 and no error", dropped, err)
+        }
+    })
+
+    t.Run("continues past failures", func(t *testing.T) {
+        store, mock := newMockStore(t)
+        mock.ExpectQuery("SELECT name FROM `sandbox`.models;").WillReturnRows(
+            sqlmock.NewRows([]string{"name"}).AddRow("a").AddRow("b").AddRow("c"))
+        mock.ExpectExec("DROP MODEL `sandbox`.`a`;").WillReturnResult(sqlmock.NewResult(0, 0))
+        mock.ExpectExec("DROP MODEL `sandbox`.`b`;").WillReturnError(errors.New("model is locked"))
+        mock.ExpectExec("DROP MODEL `sandbox`.`c`;").WillReturnResult(sqlmock.NewResult(0, 0))
+
+        dropped, err := store.DropAllModels(context.Background(), "sandbox", true)
+        if dropped != 2 {
+            t.Errorf("dropped = %d, want 2", dropped)
+        }
+        if err == nil || !strings.Contains(err.Error(), "model b") {
+            t.Errorf("DropAllModels error = %v, want the failure of b", err)
+        }
+    })
+
+    t.Run("requires confirmation", func(t *testing.T) {
+        // The mock expects no queries, so touching the database fails the test.
+        store, _ := newMockStore(t)
+        dropped, err := store.DropAllModels(context.Background(), "sandbox", false)
+        if err == nil || dropped != 0 {
+            t.Errorf("DropAllModels without confirm = %d, %v, want 0 and an error", dropped, err)
+        }
+    })
+}
+
+func TestCorrelationComment(t *testing.T) {
+    tests := []struct {
+        id   string
+        want string
+    }{
+        {"", ""},
+        {"3f9a1c0e7b2d4a55", "/* corr_id: 3f9a1c0e7b2d4a55 */ "},
+        {"req-1_a.b:c", "/* corr_id: req-1_a.b:c */ "},
+        // The comment cannot be closed early to inject SQL.
+        {"x */ DROP MODEL y; /*", "/* corr_id: xDROPMODELy */ "},
+        {"*/;--", "/* corr_id: -- */ "},
+        {strings.Repeat("a", 100), "/* corr_id: " + strings.Repeat("a", maxCorrelationIDLength) + " */ "},
+    }
+    for _, tt := range tests {
+        if got := correlationComment(tt.id); got != tt.want {
+            t.Errorf("correlationComment(%q) = %q, want %q", tt.id, got, tt.want)
+        }
+    }
+}
+
+func TestPredictCorrelationID(t *testing.T) {
+    store, _ := newMockStore(t)
+    ctx := WithCorrelationID(context.Background(), "from-context")
+
+    _, err := store.Predict(ctx, "home_rentals_model", map[string]interface{}{"sqft": 900}, DryRun())
+    var dryRun *DryRunResult
+    if !errors.As(err, &dryRun) {
+        t.Fatalf("Predict error = %v, want a DryRunResult", err)
+    }
+    if want := "/* corr_id: from-context */ " + rentalsQuery; dryRun.Query != want {
+        t.Errorf("query = %q, want %q", dryRun.Query, want)
+    }
+
+    _, err = store.Predict(ctx, "home_rentals_model", map[string]interface{}{"sqft": 900}, DryRun(), CorrelationID("x */ DROP MODEL y; /*"))
+    if !errors.As(err, &dryRun) || !strings.HasPrefix(dryRun.Query, "/* corr_id: xDROPMODELy */ SELECT") {
+        t.Errorf("query = %q, want the sanitized option ID to override the context", dryRun.Query)
+    }
+}
+
+func TestPredictDryRun(t *testing.T) {
+    // The mock expects no queries, so a dry run that reaches the database fails.
+    store, _ := newMockStore(t)
+    input := map[string]interface{}{"sqft": 900, "location": "good", "number`of`rooms": 2}
+
+    result, err := store.Predict(context.Background(), "home_rentals_model", input, DryRun())
+    if result != nil {
+        t.Errorf("result = %v, want nil", result)
+    }
+    var dryRun *DryRunResult
+    if !errors.As(err, &dryRun) {
+        t.Fatalf("Predict error = %v, want a DryRunResult", err)
+    }
+
+    // Columns are sorted so the query is stable, and backticks are escaped.
+    wantQuery := "SELECT * FROM mindsdb.`home_rentals_model` WHERE `location` = ? AND `number``of``rooms` = ? AND `sqft` = ?;"
+    if dryRun.Query != wantQuery {
+        t.Errorf("query = %q, want %q", dryRun.Query, wantQuery)
+    }
+    if want := []interface{}{"good", 2, 900}; !reflect.DeepEqual(dryRun.Args, want) {
+        t.Errorf("args = %v, want %v", dryRun.Args, want)
+    }
+}
@@ -0,0 +1,557 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+    "fmt"
+    "net"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/go-sql-driver/mysql"
+)
+
+// mysqlDuplicateEntry is the MySQL error number for a unique key violation.
+const mysqlDuplicateEntry = 1062
+
+// MySQLStore is a PredictorStore backed by a MySQL-protocol connection, such as
+// the one MindsDB exposes on port 47334.
+type MySQLStore struct {
+    db             *sql.DB
+    dialer         *net.Dialer
+    capabilities   Capabilities
+    readyWait      time.Duration
+    floatPrecision int
+}
+
+// MySQLOption configures a MySQLStore created by NewMySQLStore.
+type MySQLOption func(*MySQLStore)
+
+// WithModelReadyWait makes Predict wait up to timeout for a model that is still
+// training before giving up with ErrModelNotReady. By default Predict fails
+// immediately.
+func WithModelReadyWait(timeout time.Duration) MySQLOption {
+    return func(store *MySQLStore) {
+        store.readyWait = timeout
+    }
+}
+
+// WithPredictionFloatPrecision makes Predict round float values to decimals
+// decimal places. A negative value, the default, leaves them unrounded.
+func WithPredictionFloatPrecision(decimals int) MySQLOption {
+    return func(store *MySQLStore) {
+        store.floatPrecision = decimals
+    }
+}
+
+// WithMySQLDialer makes the store open its connections with dialer, for
+// example to set TCP keep-alives or a connect timeout.
+func WithMySQLDialer(dialer *net.Dialer) MySQLOption {
+    return func(store *MySQLStore) {
+        store.dialer = dialer
+    }
+}
+
+// dialerKey identifies a custom dialer registered with the driver for one
+// underlying network.
+type dialerKey struct {
+    dialer  *net.Dialer
+    network string
+}
+
+// dialerNetworks holds the driver network registered for each custom dialer.
+// The driver offers no way to unregister a network, so each dialer is
+// registered once and reused by every store created with it.
+var (
+    dialerNetworksMu sync.Mutex
+    dialerNetworks   = make(map[dialerKey]string)
+)
+
+// NewMySQLStore opens a connection using dsn, checks it is reachable and
+// detects its capabilities. If the server can't run prepared statements, as
+// with some MySQL proxies, the store switches to having the driver interpolate
+// query arguments instead. A probe that fails for another reason, such as a
+// timeout, makes NewMySQLStore fail rather than guess.
+func NewMySQLStore(dsn string, opts ...MySQLOption) (*MySQLStore, error) {
+    store := &MySQLStore{floatPrecision: -1}
+    for _, opt := range opts {
+        opt(store)
+    }
+
+    cfg, err := store.config(dsn)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open database: %w", err)
+    }
+
+    // Test the connection with a timeout
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    db, err := openMySQL(ctx, cfg)
+    if err != nil {
+        return nil, err
+    }
+
+    store.capabilities, err = detectCapabilities(ctx, db, cfg)
+    if err != nil {
+        db.Close()
+        return nil, err
+    }
+    if !store.capabilities.PreparedStatements && !cfg.InterpolateParams {
+        db.Close()
+        cfg.InterpolateParams = true
+        if db, err = openMySQL(ctx, cfg); err != nil {
+            return nil, err
+        }
+    }
+
+    store.db = db
+    return store, nil
+}
+
+// config parses dsn into a driver configuration. The driver only accepts
+// custom dialers registered globally under a network name, so a store with its
+// own dialer points the config at the network registered for that dialer.
+func (store *MySQLStore) config(dsn string) (*mysql.Config, error) {
+    cfg, err := mysql.ParseDSN(dsn)
+    if err != nil {
+        return nil, err
+    }
+    if store.dialer == nil {
+        return cfg, nil
+    }
+
+    cfg.Net = registerDialer(store.dialer, cfg.Net)
+    return cfg, nil
+}
+
+// registerDialer returns the driver network that dials network with dialer,
+// registering it the first time the pair is seen.
+func registerDialer(dialer *net.Dialer, network string) string {
+    dialerNetworksMu.Lock()
+    defer dialerNetworksMu.Unlock()
+
+    key := dialerKey{dialer: dialer, network: network}
+    if name, ok := dialerNetworks[key]; ok {
+        return name
+    }
+    name := fmt.Sprintf("mindsdb-dialer-%d", len(dialerNetworks)+1)
+    mysql.RegisterDialContext(name, func(ctx context.Context, addr string) (net.Conn, error) {
+        return dialer.DialContext(ctx, network, addr)
+    })
+    dialerNetworks[key] = name
+    return name
+}
+
+// openMySQL opens a database using cfg and pings it.
+func openMySQL(ctx context.Context, cfg *mysql.Config) (*sql.DB, error) {
+    connector, err := mysql.NewConnector(cfg)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open database: %w", err)
+    }
+    db := sql.OpenDB(connector)
+    if err := db.PingContext(ctx); err != nil {
+        db.Close()
+        return nil, fmt.Errorf("failed to ping database: %w", err)
+    }
+    return db, nil
+}
+
+// Capabilities describes features the server behind a MySQLStore supports.
+// Proxies in front of MindsDB may not support all of them.
+type Capabilities struct {
+    // PreparedStatements reports whether the server runs server-side prepared
+    // statements. Without them the driver interpolates query arguments itself.
+    PreparedStatements bool
+    // MultiStatements reports whether the server accepts several statements in
+    // one query. It is only probed when the DSN sets multiStatements=true.
+    MultiStatements bool
+}
+
+// detectCapabilities probes db for the features in Capabilities. A probe the
+// server rejects marks the feature unsupported; any other failure, such as a
+// timeout, is returned, since it says nothing about what the server supports.
+func detectCapabilities(ctx context.Context, db *sql.DB, cfg *mysql.Config) (Capabilities, error) {
+    var caps Capabilities
+
+    // With interpolateParams the driver never prepares a statement for a
+    // query with arguments, so prepare one explicitly.
+    stmt, err := db.PrepareContext(ctx, "SELECT ?;")
+    if err == nil {
+        var one int
+        err = stmt.QueryRowContext(ctx, 1).Scan(&one)
+        stmt.Close()
+    }
+    if caps.PreparedStatements, err = supported(err); err != nil {
+        return caps, fmt.Errorf("failed to probe prepared statements: %w", err)
+    }
+
+    if cfg.MultiStatements {
+        _, err := db.ExecContext(ctx, "SELECT 1; SELECT 1;")
+        if caps.MultiStatements, err = supported(err); err != nil {
+            return caps, fmt.Errorf("failed to probe multi-statements: %w", err)
+        }
+    }
+    return caps, nil
+}
+
+// supported interprets the error of a capability probe: nil means the feature
+// works, an error from the server means it doesn't, and any other error is
+// returned.
+func supported(err error) (bool, error) {
+    var serverErr *mysql.MySQLError
+    switch {
+    case err == nil:
+        return true, nil
+    case errors.As(err, &serverErr):
+        return false, nil
+    default:
+        return false, err
+    }
+}
+
+// Capabilities returns the features detected when the store connected.
+func (store *MySQLStore) Capabilities() Capabilities {
+    return store.capabilities
+}
+
+// Close closes the database connection. The context is unused; database/sql
+// waits for in-use connections to be returned before closing them.
+func (store *MySQLStore) Close(ctx context.Context) error {
+    return store.db.Close()
+}
+
+// Ping checks that the database is reachable.
+func (store *MySQLStore) Ping(ctx context.Context) error {
+    return store.db.PingContext(ctx)
+}
+
+// CreatePredictorsTable creates the predictors table if it doesn't exist. The
+// unique key on name makes creates and renames to a taken name fail with
+// ErrDuplicatePredictor. A table created before the key was added keeps
+// accepting duplicates until it is added with
+// "ALTER TABLE predictors ADD UNIQUE KEY uq_predictors_name (name);".
+func (store *MySQLStore) CreatePredictorsTable() error {
+    query := `
+    CREATE TABLE IF NOT EXISTS predictors (
+        id INT AUTO_INCREMENT PRIMARY KEY,
+        name VARCHAR(255) NOT NULL,
+        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+        updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        UNIQUE KEY uq_predictors_name (name)
+    );`
+
+    _, err := store.db.Exec(query)
+    return err
+}
+
+// parseRowID converts a predictor ID into the table's integer primary key.
+func parseRowID(id string) (int64, error) {
+    rowID, err := strconv.ParseInt(id, 10, 64)
+    if err != nil || rowID <= 0 {
+        return 0, ErrInvalidPredictorID
+    }
+    return rowID, nil
+}
+
+// isDuplicateEntry reports whether err is a MySQL unique key violation.
+func isDuplicateEntry(err error) bool {
+    var mysqlErr *mysql.MySQLError
+    return errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry
+}
+
+// queryPredictors runs a query selecting id and name and scans the predictors it returns.
+func (store *MySQLStore) queryPredictors(ctx context.Context, query string, args ...interface{}) ([]Predictor, error) {
+    rows, err := store.db.QueryContext(ctx, query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("error executing query: %w", err)
+    }
+    defer rows.Close()
+
+    var predictors []Predictor
+    for rows.Next() {
+        var rowID int64
+        var predictor Predictor
+        if err := rows.Scan(&rowID, &predictor.Name); err != nil {
+            return nil, fmt.Errorf("error scanning row: %w", err)
+        }
+        predictor.ID = strconv.FormatInt(rowID, 10)
+        predictors = append(predictors, predictor)
+    }
+
+    return predictors, rows.Err()
+}
+
+// CreatePredictor inserts a new predictor row and returns its ID.
+func (store *MySQLStore) CreatePredictor(ctx context.Context, predictor Predictor) (string, error) {
+    name, err := normalizePredictorName(predictor.Name)
+    if err != nil {
+        return "", err
+    }
+
+    result, err := store.db.ExecContext(ctx, "INSERT INTO predictors (name) VALUES (?);", name)
+    if isDuplicateEntry(err) {
+        return "", ErrDuplicatePredictor
+    }
+    if err != nil {
+        return "", fmt.Errorf("error creating predictor: %w", err)
+    }
+    rowID, err := result.LastInsertId()
+    if err != nil {
+        return "", fmt.Errorf("error reading predictor id: %w", err)
+    }
+    return strconv.FormatInt(rowID, 10), nil
+}
+
+// CreatePredictors inserts predictors one row at a time and returns their IDs
+// in input order. A failing row doesn't stop the rest; failures, including
+// predictors that fail Validate, are reported as a *BatchError and leave an
+// empty ID at their index.
+func (store *MySQLStore) CreatePredictors(ctx context.Context, predictors []Predictor) ([]string, error) {
+    ids := make([]string, len(predictors))
+    batchErr := &BatchError{Failed: make(map[int]error)}
+    for i, predictor := range predictors {
+        name, err := normalizePredictorName(predictor.Name)
+        if err != nil {
+            batchErr.Failed[i] = err
+            continue
+        }
+
+        result, err := store.db.ExecContext(ctx, "INSERT INTO predictors (name) VALUES (?);", name)
+        if err == nil {
+            var rowID int64
+            if rowID, err = result.LastInsertId(); err == nil {
+                ids[i] = strconv.FormatInt(rowID, 10)
+                continue
+            }
+        }
+        if ctx.Err() != nil {
+            return nil, ctx.Err()
+        }
+        if isDuplicateEntry(err) {
+            err = ErrDuplicatePredictor
+        }
+        batchErr.Failed[i] = err
+    }
+
+    if len(batchErr.Failed) > 0 {
+        return ids, batchErr
+    }
+    return ids, nil
+}
+
+// GetPredictors retrieves all predictors ordered by ID.
+func (store *MySQLStore) GetPredictors(ctx context.Context) ([]Predictor, error) {
+    return store.queryPredictors(ctx, "SELECT id, name FROM predictors ORDER BY id;")
+}
+
+// GetPredictorByID retrieves a single predictor. It returns ErrInvalidPredictorID
+// if id is not a row ID and ErrPredictorNotFound if no row has the ID.
+func (store *MySQLStore) GetPredictorByID(ctx context.Context, id string) (*Predictor, error) {
+    rowID, err := parseRowID(id)
+    if err != nil {
+        return nil, err
+    }
+
+    predictor := Predictor{ID: strconv.FormatInt(rowID, 10)}
+    err = store.db.QueryRowContext(ctx, "SELECT name FROM predictors WHERE id = ?;", rowID).Scan(&predictor.Name)
+    if errors.Is(err, sql.ErrNoRows) {
+        return nil, ErrPredictorNotFound
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error querying predictor: %w", err)
+    }
+    return &predictor, nil
+}
+
+// GetPredictorsPaged returns up to limit predictors starting at offset, ordered by ID.
+func (store *MySQLStore) GetPredictorsPaged(ctx context.Context, limit, offset int64) ([]Predictor, error) {
+    if limit <= 0 {
+        return nil, fmt.Errorf("limit must be positive, got %d", limit)
+    }
+    if offset < 0 {
+        return nil, fmt.Errorf("offset must not be negative, got %d", offset)
+    }
+    return store.queryPredictors(ctx, "SELECT id, name FROM predictors ORDER BY id LIMIT ? OFFSET ?;", limit, offset)
+}
+
+// GetPredictorsCursor returns up to limit predictors with IDs after afterID, in
+// ID order, together with the cursor for the next page. An empty afterID starts
+// from the beginning; a page shorter than limit has an empty next cursor.
+func (store *MySQLStore) GetPredictorsCursor(ctx context.Context, afterID string, limit int) ([]Predictor, string, error) {
+    if limit <= 0 {
+        return nil, "", fmt.Errorf("limit must be positive, got %d", limit)
+    }
+
+    var after int64
+    if afterID != "" {
+        var err error
+        if after, err = parseRowID(afterID); err != nil {
+            return nil, "", err
+        }
+    }
+
+    predictors, err := store.queryPredictors(ctx, "SELECT id, name FROM predictors WHERE id > ? ORDER BY id LIMIT ?;", after, limit)
+    if err != nil {
+        return nil, "", err
+    }
+
+    next := ""
+    if len(predictors) == limit {
+        next = predictors[limit-1].ID
+    }
+    return predictors, next, nil
+}
+
+// CountPredictors returns the number of predictors.
+func (store *MySQLStore) CountPredictors(ctx context.Context) (int64, error) {
+    var count int64
+    if err := store.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM predictors;").Scan(&count); err != nil {
+        return 0, fmt.Errorf("error counting predictors: %w", err)
+    }
+    return count, nil
+}
+
+// EstimatePredictorCount returns the exact count; the table statistics MySQL
+// keeps for estimates can be far off for small tables.
+func (store *MySQLStore) EstimatePredictorCount(ctx context.Context) (int64, error) {
+    return store.CountPredictors(ctx)
+}
+
+// likeEscaper escapes the LIKE wildcards and the escape character itself.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// SearchPredictors returns up to limit predictors, starting at offset, whose
+// names contain query, ignoring case, sorted by name. LIKE wildcards in query
+// are matched literally.
+func (store *MySQLStore) SearchPredictors(ctx context.Context, query string, limit, offset int64) ([]Predictor, error) {
+    if limit <= 0 {
+        return nil, fmt.Errorf("limit must be positive, got %d", limit)
+    }
+    if offset < 0 {
+        return nil, fmt.Errorf("offset must not be negative, got %d", offset)
+    }
+    pattern := "%" + likeEscaper.Replace(strings.ToLower(query)) + "%"
+    return store.queryPredictors(ctx, "SELECT id, name FROM predictors WHERE LOWER(name) LIKE ? ORDER BY name LIMIT ? OFFSET ?;", pattern, limit, offset)
+}
+
+// UpdatePredictor replaces the fields of the predictor with the given ID. It
+// returns a *ValidationError if the predictor fails Validate.
+func (store *MySQLStore) UpdatePredictor(ctx context.Context, id string, predictor Predictor) error {
+    name, err := normalizePredictorName(predictor.Name)
+    if err != nil {
+        return err
+    }
+    return store.setName(ctx, id, name)
+}
+
+// RenamePredictor changes the name of the predictor with the given ID. It returns
+// ErrDuplicatePredictor if another predictor already uses the new name,
+// ErrPredictorNotFound if no predictor has the ID and a *ValidationError if
+// Validate rejects the name.
+func (store *MySQLStore) RenamePredictor(ctx context.Context, id, newName string) error {
+    if _, err := parseRowID(id); err != nil {
+        return err
+    }
+    newName, err := normalizePredictorName(newName)
+    if err != nil {
+        return err
+    }
+
+    // The unique key on name rejects a taken name atomically; checking first
+    // would race with concurrent renames.
+    return store.setName(ctx, id, newName)
+}
+
+// PatchPredictor applies patch to the predictor with the given ID, accepting
+// the same fields as MindsDBClient.PatchPredictor.
+func (store *MySQLStore) PatchPredictor(ctx context.Context, id string, patch map[string]interface{}) error {
+    name, err := patchedName(patch)
+    if err != nil {
+        return err
+    }
+    return store.RenamePredictor(ctx, id, name)
+}
+
+// setName writes name to the predictor with the given ID.
+func (store *MySQLStore) setName(ctx context.Context, id, name string) error {
+    rowID, err := parseRowID(id)
+    if err != nil {
+        return err
+    }
+
+    result, err := store.db.ExecContext(ctx, "UPDATE predictors SET name = ? WHERE id = ?;", name, rowID)
+    if isDuplicateEntry(err) {
+        return ErrDuplicatePredictor
+    }
+    if err != nil {
+        return fmt.Errorf("error updating predictor: %w", err)
+    }
+
+    // MySQL reports zero affected rows when the name is unchanged, so confirm
+    // the row exists before reporting it missing.
+    if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+        return store.ensureExists(ctx, rowID)
+    }
+    return nil
+}
+
+// DeletePredictor removes the predictor with the given ID. It returns
+// ErrInvalidPredictorID if id is not a row ID and ErrPredictorNotFound if no
+// row has the ID.
+func (store *MySQLStore) DeletePredictor(ctx context.Context, id string) error {
+    rowID, err := parseRowID(id)
+    if err != nil {
+        return err
+    }
+
+    result, err := store.db.ExecContext(ctx, "DELETE FROM predictors WHERE id = ?;", rowID)
+    if err != nil {
+        return fmt.Errorf("error deleting predictor: %w", err)
+    }
+    affected, err := result.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("error deleting predictor: %w", err)
+    }
+    if affected == 0 {
+        return ErrPredictorNotFound
+    }
+    return nil
+}
+
+// DeletePredictorsByName removes every predictor whose name starts with prefix
+// and returns how many were deleted. LIKE wildcards in prefix are matched
+// literally; case sensitivity follows the name column's collation. It returns
+// ErrEmptyPrefix rather than deleting everything.
+func (store *MySQLStore) DeletePredictorsByName(ctx context.Context, prefix string) (int64, error) {
+    if prefix == "" {
+        return 0, ErrEmptyPrefix
+    }
+
+    result, err := store.db.ExecContext(ctx, "DELETE FROM predictors WHERE name LIKE ?;", likeEscaper.Replace(prefix)+"%")
+    if err != nil {
+        return 0, fmt.Errorf("error deleting predictors: %w", err)
+    }
+    deleted, err := result.RowsAffected()
+    if err != nil {
+        return 0, fmt.Errorf("error deleting predictors: %w", err)
+    }
+    return deleted, nil
+}
+
+// ensureExists returns ErrPredictorNotFound unless a row has rowID.
+func (store *MySQLStore) ensureExists(ctx context.Context, rowID int64) error {
+    var exists int
+    err := store.db.QueryRowContext(ctx, "SELECT 1 FROM predictors WHERE id = ?;", rowID).Scan(&exists)
+    if errors.Is(err, sql.ErrNoRows) {
+        return ErrPredictorNotFound
+    }
+    if err != nil {
+        return fmt.Errorf("error querying predictor: %w", err)
+    }
+    return nil
+}
+
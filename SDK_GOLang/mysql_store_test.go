@@ -0,0 +1,163 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "net"
+    "sync/atomic"
+    "syscall"
+    "testing"
+    "time"
+
+    "github.com/DATA-DOG/go-sqlmock"
+    "github.com/go-sql-driver/mysql"
+)
+
+const renameQuery = "UPDATE predictors SET name = ? WHERE id = ?;"
+
+func TestMySQLRenamePredictor(t *testing.T) {
+    tests := []struct {
+        name    string
+        setup   func(mock sqlmock.Sqlmock)
+        wantErr error
+    }{
+        {"success", func(mock sqlmock.Sqlmock) {
+            mock.ExpectExec(renameQuery).WithArgs("churn", 7).WillReturnResult(sqlmock.NewResult(0, 1))
+        }, nil},
+        {"conflict", func(mock sqlmock.Sqlmock) {
+            mock.ExpectExec(renameQuery).WithArgs("churn", 7).
+                WillReturnError(&mysql.MySQLError{Number: mysqlDuplicateEntry, Message: "Duplicate entry 'churn' for key 'uq_predictors_name'"})
+        }, ErrDuplicatePredictor},
+        {"not found", func(mock sqlmock.Sqlmock) {
+            mock.ExpectExec(renameQuery).WithArgs("churn", 7).WillReturnResult(sqlmock.NewResult(0, 0))
+            mock.ExpectQuery("SELECT 1 FROM predictors WHERE id = ?;").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"1"}))
+        }, ErrPredictorNotFound},
+        {"unchanged name", func(mock sqlmock.Sqlmock) {
+            mock.ExpectExec(renameQuery).WithArgs("churn", 7).WillReturnResult(sqlmock.NewResult(0, 0))
+            mock.ExpectQuery("SELECT 1 FROM predictors WHERE id = ?;").WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+        }, nil},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            store, mock := newMockStore(t)
+            tt.setup(mock)
+
+            err := store.RenamePredictor(context.Background(), "7", "  churn ")
+            if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+                t.Errorf("RenamePredictor error = %v, want %v", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestMySQLRenamePredictorInvalidID(t *testing.T) {
+    store, _ := newMockStore(t)
+    if err := store.RenamePredictor(context.Background(), "abc", "churn"); !errors.Is(err, ErrInvalidPredictorID) {
+        t.Errorf("RenamePredictor error = %v, want ErrInvalidPredictorID", err)
+    }
+}
+
+func TestMySQLSearchPredictorsPagesInQuery(t *testing.T) {
+    store, mock := newMockStore(t)
+    mock.ExpectQuery("SELECT id, name FROM predictors WHERE LOWER(name) LIKE ? ORDER BY name LIMIT ? OFFSET ?;").
+        WithArgs(`%50\%\_off%`, 5, 10).
+        WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(3, "50%_off_a"))
+
+    predictors, err := store.SearchPredictors(context.Background(), "50%_OFF", 5, 10)
+    if err != nil {
+        t.Fatalf("SearchPredictors: %v", err)
+    }
+    if len(predictors) != 1 || predictors[0] != (Predictor{ID: "3", Name: "50%_off_a"}) {
+        t.Errorf("predictors = %+v, want [{3 50%%_off_a}]", predictors)
+    }
+}
+
+func TestMySQLDialer(t *testing.T) {
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatalf("net.Listen: %v", err)
+    }
+    defer listener.Close()
+    // Close every connection straight away; the test only checks the dial.
+    go func() {
+        for {
+            conn, err := listener.Accept()
+            if err != nil {
+                return
+            }
+            conn.Close()
+        }
+    }()
+
+    var dials atomic.Int32
+    dialer := &net.Dialer{Timeout: time.Second, Control: func(network, address string, c syscall.RawConn) error {
+        dials.Add(1)
+        return nil
+    }}
+    dsn := "user:pass@tcp(" + listener.Addr().String() + ")/mindsdb"
+    if _, err := NewMySQLStore(dsn, WithMySQLDialer(dialer)); err == nil {
+        t.Fatal("NewMySQLStore against a closed connection: err = nil")
+    }
+    if dials.Load() == 0 {
+        t.Error("the custom dialer was not used")
+    }
+
+    // Stores sharing a dialer share its registration instead of adding another.
+    store := &MySQLStore{dialer: dialer}
+    first, err := store.config(dsn)
+    if err != nil {
+        t.Fatalf("config: %v", err)
+    }
+    second, _ := store.config(dsn)
+    if first.Net != second.Net {
+        t.Errorf("networks %q and %q, want the same registration", first.Net, second.Net)
+    }
+    other, _ := (&MySQLStore{dialer: &net.Dialer{}}).config(dsn)
+    if other.Net == first.Net {
+        t.Errorf("a different dialer reuses network %q", other.Net)
+    }
+}
+
+func TestDetectCapabilities(t *testing.T) {
+    unsupported := &mysql.MySQLError{Number: 1295, Message: "This command is not supported in the prepared statement protocol yet"}
+    tests := []struct {
+        name    string
+        setup   func(mock sqlmock.Sqlmock)
+        want    Capabilities
+        wantErr error
+    }{
+        {"full support", func(mock sqlmock.Sqlmock) {
+            mock.ExpectPrepare("SELECT ?;").ExpectQuery().WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"?"}).AddRow(1))
+            mock.ExpectExec("SELECT 1; SELECT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+        }, Capabilities{PreparedStatements: true, MultiStatements: true}, nil},
+        {"limited proxy", func(mock sqlmock.Sqlmock) {
+            mock.ExpectPrepare("SELECT ?;").WillReturnError(unsupported)
+            mock.ExpectExec("SELECT 1; SELECT 1;").WillReturnError(&mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"})
+        }, Capabilities{}, nil},
+        {"prepared query rejected", func(mock sqlmock.Sqlmock) {
+            mock.ExpectPrepare("SELECT ?;").ExpectQuery().WithArgs(1).WillReturnError(unsupported)
+            mock.ExpectExec("SELECT 1; SELECT 1;").WillReturnResult(sqlmock.NewResult(0, 0))
+        }, Capabilities{MultiStatements: true}, nil},
+        {"prepare timeout", func(mock sqlmock.Sqlmock) {
+            mock.ExpectPrepare("SELECT ?;").WillReturnError(context.DeadlineExceeded)
+        }, Capabilities{}, context.DeadlineExceeded},
+        {"multi-statement timeout", func(mock sqlmock.Sqlmock) {
+            mock.ExpectPrepare("SELECT ?;").ExpectQuery().WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"?"}).AddRow(1))
+            mock.ExpectExec("SELECT 1; SELECT 1;").WillReturnError(context.DeadlineExceeded)
+        }, Capabilities{PreparedStatements: true}, context.DeadlineExceeded},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            store, mock := newMockStore(t)
+            tt.setup(mock)
+
+            caps, err := detectCapabilities(context.Background(), store.db, &mysql.Config{MultiStatements: true})
+            if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+                t.Fatalf("detectCapabilities error = %v, want %v", err, tt.wantErr)
+            }
+            if caps != tt.want {
+                t.Errorf("detectCapabilities = %+v, want %+v", caps, tt.want)
+            }
+        })
+    }
+}
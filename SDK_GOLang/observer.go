@@ -0,0 +1,65 @@
+package main
+
+import (
+    "fmt"
+    "sync"
+
+    "go.mongodb.org/mongo-driver/event"
+)
+
+// ConnectionObserver receives connection state changes from a MindsDBClient,
+// which helps correlate query failures with connection churn. Callbacks run
+// on driver goroutines and must not block.
+type ConnectionObserver interface {
+    // OnConnect is called for each configured host once NewMindsDBClient has connected.
+    OnConnect(address string)
+    // OnDisconnect is called when a pooled connection to address is closed.
+    OnDisconnect(address string, reason string)
+    // OnReconnect is called when a connection to address is ready again after its pool was cleared.
+    OnReconnect(address string)
+    // OnError is called when connecting fails or a connection cannot be checked out.
+    OnError(address string, err error)
+}
+
+// WithConnectionObserver registers observer for connection events. No observer is set by default.
+func WithConnectionObserver(observer ConnectionObserver) ClientOption {
+    return func(cfg *clientConfig) {
+        cfg.observer = observer
+    }
+}
+
+// poolObserver translates driver pool events into ConnectionObserver callbacks.
+type poolObserver struct {
+    observer ConnectionObserver
+
+    mu      sync.Mutex
+    cleared map[string]bool
+}
+
+func newPoolObserver(observer ConnectionObserver) *poolObserver {
+    return &poolObserver{observer: observer, cleared: make(map[string]bool)}
+}
+
+func (p *poolObserver) handle(evt *event.PoolEvent) {
+    switch evt.Type {
+    case event.ConnectionClosed:
+        p.observer.OnDisconnect(evt.Address, evt.Reason)
+    case event.PoolCleared:
+        p.mu.Lock()
+        p.cleared[evt.Address] = true
+        p.mu.Unlock()
+        if evt.Error != nil {
+            p.observer.OnError(evt.Address, evt.Error)
+        }
+    case event.ConnectionReady:
+        p.mu.Lock()
+        reconnected := p.cleared[evt.Address]
+        delete(p.cleared, evt.Address)
+        p.mu.Unlock()
+        if reconnected {
+            p.observer.OnReconnect(evt.Address)
+        }
+    case event.GetFailed:
+        p.observer.OnError(evt.Address, fmt.Errorf("connection checkout failed: %s", evt.Reason))
+    }
+}
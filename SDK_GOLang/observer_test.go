@@ -0,0 +1,212 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "reflect"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/event"
+)
+
+// recordingObserver records every callback as a string, in order.
+type recordingObserver struct {
+    mu    sync.Mutex
+    calls []string
+}
+
+func (o *recordingObserver) record(format string, args ...interface{}) {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+    o.calls = append(o.calls, fmt.Sprintf(format, args...))
+}
+
+func (o *recordingObserver) OnConnect(address string) { o.record("connect %s", address) }
+
+func (o *recordingObserver) OnDisconnect(address string, reason string) {
+    o.record("disconnect %s %s", address, reason)
+}
+
+func (o *recordingObserver) OnReconnect(address string) { o.record("reconnect %s", address) }
+
+func (o *recordingObserver) OnError(address string, err error) { o.record("error %s", address) }
+
+func (o *recordingObserver) Calls() []string {
+    o.mu.Lock()
+    defer o.mu.Unlock()
+    return append([]string(nil), o.calls...)
+}
+
+func TestPoolObserverHandle(t *testing.T) {
+    recorder := &recordingObserver{}
+    observer := newPoolObserver(recorder)
+
+    for _, evt := range []*event.PoolEvent{
+        {Type: event.ConnectionReady, Address: "a:27017"},
+        {Type: event.ConnectionClosed, Address: "a:27017", Reason: event.ReasonIdle},
+        {Type: event.PoolCleared, Address: "a:27017", Error: errors.New("network error")},
+        {Type: event.PoolCleared, Address: "b:27017"},
+        {Type: event.ConnectionReady, Address: "a:27017"},
+        {Type: event.ConnectionReady, Address: "a:27017"},
+        {Type: event.GetFailed, Address: "b:27017", Reason: event.ReasonTimedOut},
+        {Type: event.ConnectionReady, Address: "b:27017"},
+    } {
+        observer.handle(evt)
+    }
+
+    want := []string{
+        "disconnect a:27017 idle",
+        "error a:27017",
+        "reconnect a:27017",
+        "error b:27017",
+        "reconnect b:27017",
+    }
+    if got := recorder.Calls(); !reflect.DeepEqual(got, want) {
+        t.Errorf("calls = %q, want %q", got, want)
+    }
+}
+
+func TestConnectionObserverConnectError(t *testing.T) {
+    recorder := &recordingObserver{}
+    // Nothing listens on port 1, so connecting fails quickly.
+    _, err := NewMindsDBClient("mongodb://127.0.0.1:1/?directConnection=true",
+        WithDatabase("mindsdb"), WithCollection("predictors"), WithConnectTimeout(200*time.Millisecond), WithConnectionObserver(recorder))
+    if err == nil {
+        t.Fatal("NewMindsDBClient: err = nil")
+    }
+    // The pool may report the failed host first; the connect error itself
+    // has no single address and comes last, and OnConnect never fires.
+    calls := recorder.Calls()
+    if len(calls) == 0 || calls[len(calls)-1] != "error " {
+        t.Errorf("calls = %q, want them to end with the connect error", calls)
+    }
+    for _, call := range calls {
+        if strings.HasPrefix(call, "connect ") {
+            t.Errorf("calls = %q, want no OnConnect", calls)
+        }
+    }
+}
+
+// fakeMongo answers the commands a client needs to connect and ping, as a
+// standalone server, on a local listener. It returns the listener's address.
+func fakeMongo(t *testing.T) string {
+    t.Helper()
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatalf("net.Listen: %v", err)
+    }
+    t.Cleanup(func() { listener.Close() })
+
+    go func() {
+        for {
+            conn, err := listener.Accept()
+            if err != nil {
+                return
+            }
+            go serveFakeMongo(conn)
+        }
+    }()
+    return listener.Addr().String()
+}
+
+// Wire protocol opcodes used by fakeMongo.
+const (
+    opReply = 1
+    opQuery = 2004
+    opMsg   = 2013
+)
+
+func serveFakeMongo(conn net.Conn) {
+    defer conn.Close()
+    for {
+        header := make([]byte, 16)
+        if _, err := io.ReadFull(conn, header); err != nil {
+            return
+        }
+        body := make([]byte, binary.LittleEndian.Uint32(header)-16)
+        if _, err := io.ReadFull(conn, body); err != nil {
+            return
+        }
+        requestID := binary.LittleEndian.Uint32(header[4:])
+        opcode := binary.LittleEndian.Uint32(header[12:])
+
+        var command bson.Raw
+        switch opcode {
+        case opQuery:
+            // flags, then the collection name, then numberToSkip and numberToReturn.
+            collectionEnd := 4 + bytes.IndexByte(body[4:], 0) + 1
+            command = body[collectionEnd+8:]
+        case opMsg:
+            // flagBits, then a kind 0 section holding the command.
+            command = body[5:]
+        default:
+            return
+        }
+
+        reply := bson.D{{Key: "ok", Value: 1}}
+        if name := command.Index(0).Key(); name == "isMaster" || name == "ismaster" || name == "hello" {
+            reply = append(reply,
+                bson.E{Key: "ismaster", Value: true},
+                bson.E{Key: "isWritablePrimary", Value: true},
+                bson.E{Key: "minWireVersion", Value: 0},
+                bson.E{Key: "maxWireVersion", Value: 21},
+            )
+        }
+        doc, _ := bson.Marshal(reply)
+
+        var out []byte
+        if opcode == opQuery {
+            // responseFlags, cursorID, startingFrom and numberReturned.
+            out = binary.LittleEndian.AppendUint32(out, 0)
+            out = binary.LittleEndian.AppendUint64(out, 0)
+            out = binary.LittleEndian.AppendUint32(out, 0)
+            out = binary.LittleEndian.AppendUint32(out, 1)
+            out = append(out, doc...)
+        } else {
+            out = binary.LittleEndian.AppendUint32(out, 0)
+            out = append(out, 0)
+            out = append(out, doc...)
+        }
+        message := binary.LittleEndian.AppendUint32(nil, uint32(16+len(out)))
+        message = binary.LittleEndian.AppendUint32(message, requestID+1)
+        message = binary.LittleEndian.AppendUint32(message, requestID)
+        replyOpcode := uint32(opMsg)
+        if opcode == opQuery {
+            replyOpcode = opReply
+        }
+        message = binary.LittleEndian.AppendUint32(message, replyOpcode)
+        if _, err := conn.Write(append(message, out...)); err != nil {
+            return
+        }
+    }
+}
+
+func TestConnectionObserverOnConnect(t *testing.T) {
+    address := fakeMongo(t)
+    recorder := &recordingObserver{}
+    client, err := NewMindsDBClient("mongodb://"+address+"/?directConnection=true",
+        WithDatabase("mindsdb"), WithCollection("predictors"), WithConnectTimeout(5*time.Second), WithConnectionObserver(recorder))
+    if err != nil {
+        t.Fatalf("NewMindsDBClient: %v", err)
+    }
+    defer client.Close(context.Background())
+
+    var connects []string
+    for _, call := range recorder.Calls() {
+        if strings.HasPrefix(call, "connect ") {
+            connects = append(connects, call)
+        }
+    }
+    if want := []string{"connect " + address}; !reflect.DeepEqual(connects, want) {
+        t.Errorf("OnConnect calls = %q, want %q", connects, want)
+    }
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+    "crypto/tls"
+    "errors"
+    "net"
+    "time"
+
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultConnectTimeout bounds the initial connection when WithConnectTimeout isn't given.
+const defaultConnectTimeout = 10 * time.Second
+
+// clientConfig collects the settings applied by ClientOption values.
+type clientConfig struct {
+    database               string
+    collection             string
+    connectTimeout         time.Duration
+    serverSelectionTimeout time.Duration
+    maxPoolSize            uint64
+    minPoolSize            uint64
+    maxConnIdleTime        time.Duration
+    dialer                 *net.Dialer
+    tlsConfig              *tls.Config
+    auth                   *options.Credential
+    connectRetries         int
+    retryBaseDelay         time.Duration
+    fields                 FieldMap
+    shardCount             int
+    auditCollection        string
+    observer               ConnectionObserver
+    metrics                *Metrics
+}
+
+func defaultClientConfig() clientConfig {
+    return clientConfig{
+        connectTimeout: defaultConnectTimeout,
+        fields:         DefaultFieldMap,
+    }
+}
+
+// validate reports settings that would leave the client unusable.
+func (cfg clientConfig) validate() error {
+    if cfg.database == "" {
+        return errors.New("invalid client configuration: database name is required, set it with WithDatabase")
+    }
+    if cfg.collection == "" {
+        return errors.New("invalid client configuration: collection name is required, set it with WithCollection")
+    }
+    if cfg.connectTimeout <= 0 {
+        return errors.New("invalid client configuration: connect timeout must be positive")
+    }
+    if cfg.auth != nil && cfg.auth.Username == "" {
+        return errors.New("invalid client configuration: WithAuth requires a username")
+    }
+    if cfg.connectRetries < 0 {
+        return errors.New("invalid client configuration: connect retries must not be negative")
+    }
+    if cfg.connectRetries > 0 && cfg.retryBaseDelay <= 0 {
+        return errors.New("invalid client configuration: retry base delay must be positive")
+    }
+    if cfg.maxPoolSize > 0 && cfg.minPoolSize > cfg.maxPoolSize {
+        return errors.New("invalid client configuration: min pool size exceeds max pool size")
+    }
+    return nil
+}
+
+// ClientOption configures a MindsDBClient created by NewMindsDBClient.
+type ClientOption func(*clientConfig)
+
+// WithDatabase sets the MongoDB database holding the predictors.
+func WithDatabase(name string) ClientOption {
+    return func(cfg *clientConfig) {
+        cfg.database = name
+    }
+}
+
+// WithCollection sets the collection holding the predictors.
+func WithCollection(name string) ClientOption {
+    return func(cfg *clientConfig) {
+        cfg.collection = name
+    }
+}
+
+// WithConnectTimeout bounds how long connecting to MongoDB may take. The default is 10 seconds.
+func WithConnectTimeout(d time.Duration) ClientOption {
+    return func(cfg *clientConfig) {
+        cfg.connectTimeout = d
+    }
+}
+
+// WithConnectRetries makes NewMindsDBClient retry a failed connection up to n
+// times. The first retry waits around baseDelay, and each later one about twice
+// as long as the last, up to 30 seconds. Each attempt is still bounded by the
+// connect timeout.
+func WithConnectRetries(n int, baseDelay time.Duration) ClientOption {
+    return func(cfg *clientConfig) {
+        cfg.connectRetries = n
+        cfg.retryBaseDelay = baseDelay
+    }
+}
+
+// WithServerSelectionTimeout bounds how long an operation waits for a suitable
+// server. Zero keeps the driver default of 30 seconds.
+func WithServerSelectionTimeout(d time.Duration) ClientOption {
+    return func(cfg *clientConfig) {
+        cfg.serverSelectionTimeout = d
+    }
+}
+
+// WithMaxPoolSize caps the number of connections kept open per server. Zero
+// keeps the driver default of 100.
+func WithMaxPoolSize(n uint64) ClientOption {
+    return func(cfg *clientConfig) {
+        cfg.maxPoolSize = n
+    }
+}
+
+// WithMinPoolSize keeps at least n connections open per server, so bursts of
+// traffic don't wait on new connections. Zero keeps the driver default of 0.
+func WithMinPoolSize(n uint64) ClientOption {
+    return func(cfg *clientConfig) {
+        cfg.minPoolSize = n
+    }
+}
+
+// WithMaxConnIdleTime closes pooled connections that have been idle for
+// longer than d. Zero keeps the driver default of never closing them.
+func WithMaxConnIdleTime(d time.Duration) ClientOption {
+    return func(cfg *clientConfig) {
+        cfg.maxConnIdleTime = d
+    }
+}
+
+// WithDialer makes the client open its connections with dialer, for example
+// to set TCP keep-alives or a connect timeout.
+func WithDialer(dialer *net.Dialer) ClientOption {
+    return func(cfg *clientConfig) {
+        cfg.dialer = dialer
+    }
+}
+
+// WithTLSConfig makes the client connect over TLS using tlsConfig, for example
+// to trust a custom CA. mongodb+srv URIs enable TLS by default.
+func WithTLSConfig(tlsConfig *tls.Config) ClientOption {
+    return func(cfg *clientConfig) {
+        cfg.tlsConfig = tlsConfig
+    }
+}
+
+// WithAuth authenticates as username against the authSource database, so
+// credentials don't have to be embedded in the URI. They replace any
+// credentials the URI does contain. An empty authSource uses the URI's
+// authSource option if it has one, and otherwise the driver default, which is
+// the database in the URI or "admin".
+func WithAuth(username, password, authSource string) ClientOption {
+    return func(cfg *clientConfig) {
+        cfg.auth = &options.Credential{
+            Username:   username,
+            Password:   password,
+            AuthSource: authSource,
+        }
+    }
+}
+
+// WithFieldMap makes the client read and write predictors using the given
+// document field names. Empty entries keep their default name.
+func WithFieldMap(fields FieldMap) ClientOption {
+    return func(cfg *clientConfig) {
+        if fields.Name != "" {
+            cfg.fields.Name = fields.Name
+        }
+    }
+}
+
+// WithAudit records every predictor change in the named collection of the
+// same database. Auditing is disabled unless this option is given.
+func WithAudit(collectionName string) ClientOption {
+    return func(cfg *clientConfig) {
+        cfg.auditCollection = collectionName
+    }
+}
+
+// WithShards distributes predictors across n collections named
+// "<collection>_0" to "<collection>_<n-1>", chosen by a hash of the predictor
+// name. Values below 2 keep all predictors in the configured collection.
+// Changing the shard count does not move existing documents.
+func WithShards(n int) ClientOption {
+    return func(cfg *clientConfig) {
+        cfg.shardCount = n
+    }
+}